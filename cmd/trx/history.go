@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"trx/internal/command"
+	"trx/internal/config"
+	"trx/internal/history"
+	"trx/internal/storage"
+)
+
+type historyOptions struct {
+	query  history.Query
+	asJson bool
+}
+
+func showHistory(opts historyOptions) error {
+	log.SetFlags(0)
+	log.SetOutput(os.Stderr)
+
+	cfg, err := config.NewConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("config error: %w", err)
+	}
+
+	storage, err := storage.NewStorage(&storage.StorageOpts{
+		Config: cfg,
+	})
+	if err != nil {
+		return fmt.Errorf("init storage error: %w", err)
+	}
+
+	records, err := storage.History(opts.query)
+	if err != nil {
+		return fmt.Errorf("get history error: %w", err)
+	}
+
+	if opts.asJson {
+		return printHistoryJson(os.Stdout, records)
+	}
+	return printHistoryTable(os.Stdout, records)
+}
+
+func printHistoryJson(out io.Writer, records []history.Record) error {
+	if records == nil {
+		records = []history.Record{}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+func printHistoryTable(out io.Writer, records []history.Record) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tTAG\tCOMMIT\tSTARTED\tDURATION\tSTATUS\tEXIT CODE")
+	for _, r := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			r.ID,
+			r.Kind,
+			r.Tag,
+			command.ShortSha(r.Commit),
+			r.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			r.FinishedAt.Sub(r.StartedAt).Round(time.Second),
+			r.Status,
+			r.ExitCode,
+		)
+	}
+	return w.Flush()
+}
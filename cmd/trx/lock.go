@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"trx/internal/config"
+	"trx/internal/lock"
+)
+
+func newLocker() (lock.Locker, *config.Config, error) {
+	log.SetFlags(0)
+	log.SetOutput(os.Stderr)
+
+	cfg, err := config.NewConfig(configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config error: %w", err)
+	}
+
+	locker, err := lock.NewLocker(cfg.Lock)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init lock error: %w", err)
+	}
+	return locker, cfg, nil
+}
+
+func showLockStatus(asJson bool) error {
+	locker, cfg, err := newLocker()
+	if err != nil {
+		return err
+	}
+
+	status, err := locker.Status(cfg.Repo.Url)
+	if err != nil {
+		return fmt.Errorf("get lock status error: %w", err)
+	}
+
+	if asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+	return printLockStatus(os.Stdout, status)
+}
+
+func printLockStatus(out io.Writer, status *lock.Status) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "STATUS:\t%s\n", lockState(status))
+	if h := status.Holder; h != nil {
+		fmt.Fprintf(w, "PID:\t%d\n", h.PID)
+		fmt.Fprintf(w, "HOST:\t%s\n", h.Host)
+		if h.Tag != "" {
+			fmt.Fprintf(w, "TAG:\t%s\n", h.Tag)
+		}
+		fmt.Fprintf(w, "STARTED:\t%s\n", h.StartedAt.Local().Format(time.DateTime))
+	}
+	if !status.ExpiresAt.IsZero() {
+		fmt.Fprintf(w, "EXPIRES:\t%s\n", status.ExpiresAt.Local().Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func breakLock(force bool) error {
+	locker, cfg, err := newLocker()
+	if err != nil {
+		return err
+	}
+
+	status, err := locker.Status(cfg.Repo.Url)
+	if err != nil {
+		return fmt.Errorf("get lock status error: %w", err)
+	}
+	if !status.Locked {
+		log.Println("Lock is not held")
+		return nil
+	}
+	if !status.Stale && !force {
+		return fmt.Errorf("lock is held by an active run, use --force to break it anyway")
+	}
+
+	if err := locker.Break(cfg.Repo.Url); err != nil {
+		return fmt.Errorf("break lock error: %w", err)
+	}
+	log.Println("Lock is broken")
+	return nil
+}
+
+func lockState(status *lock.Status) string {
+	switch {
+	case status.Stale:
+		return "stale"
+	case status.Locked:
+		return "held"
+	default:
+		return "free"
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"trx/internal/config"
+	"trx/internal/git"
+	"trx/internal/history"
+	"trx/internal/runlog"
+)
+
+type logsOptions struct {
+	step    string
+	summary bool
+}
+
+func showLogs(runID string, opts logsOptions) error {
+	log.SetFlags(0)
+	log.SetOutput(os.Stderr)
+
+	cfg, err := config.NewConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("config error: %w", err)
+	}
+
+	baseDir, err := runsDir(cfg)
+	if err != nil {
+		return err
+	}
+
+	if runID == "" {
+		ids, err := runlog.List(baseDir)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			fmt.Println(id)
+		}
+		return nil
+	}
+
+	dir := filepath.Join(baseDir, runID)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("run %s not found: %w", runID, err)
+	}
+
+	path := filepath.Join(dir, runlog.FileOutput)
+	switch {
+	case opts.summary:
+		path = filepath.Join(dir, runlog.FileSummary)
+	case opts.step != "":
+		path, err = runlog.FindStepLog(dir, opts.step)
+		if err != nil {
+			return err
+		}
+	}
+	return printFile(os.Stdout, path)
+}
+
+func printFile(out io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(out, f)
+	return err
+}
+
+func runsDir(cfg *config.Config) (string, error) {
+	var path string
+	if cfg.Runs != nil {
+		path = cfg.Runs.Path
+	}
+	dir, err := runlog.BaseDir(path, git.RepoKeyFromUrl(cfg.Repo.Url))
+	if err != nil {
+		return "", fmt.Errorf("get runs directory error: %w", err)
+	}
+	return dir, nil
+}
+
+// startRunLog creates the run directory and copies the log output to it.
+// Runs proceed without the directory if it can't be created.
+func (r *runner) startRunLog(runID string) *runlog.Run {
+	baseDir, err := runsDir(r.cfg)
+	if err != nil {
+		log.Printf("WARNING %s", err.Error())
+		return nil
+	}
+	run, err := runlog.Create(baseDir, runID)
+	if err != nil {
+		log.Printf("WARNING %s", err.Error())
+		return nil
+	}
+	log.SetOutput(io.MultiWriter(log.Writer(), run))
+	return run
+}
+
+// stopRunLog writes the run summary, closes the run directory and removes the runs beyond the retention limits.
+func (r *runner) stopRunLog(run *runlog.Run, record *history.Record) {
+	if run == nil {
+		return
+	}
+	if err := run.WriteSummary(*record); err != nil {
+		log.Printf("WARNING unable to write run summary: %s", err.Error())
+	}
+	log.SetOutput(os.Stdout)
+	if err := run.Close(); err != nil {
+		log.Printf("WARNING unable to close run output: %s", err.Error())
+	}
+
+	keepLast, maxAge := runlog.DefaultKeepLast, time.Duration(0)
+	if r.cfg.Runs != nil {
+		if r.cfg.Runs.KeepLast > 0 {
+			keepLast = r.cfg.Runs.KeepLast
+		}
+		maxAge = r.cfg.Runs.MaxAge
+	}
+	if err := runlog.Cleanup(filepath.Dir(run.Dir), keepLast, maxAge); err != nil {
+		log.Printf("WARNING unable to clean up old runs: %s", err.Error())
+	}
+}
@@ -1,22 +1,41 @@
 package main
 
 import (
+	"errors"
 	"log"
+	"os"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"trx/internal/sandbox"
 )
 
 var (
 	configPath  string
 	force       bool
 	disableLock bool
+	interval    time.Duration
+	tag         string
+	rollbackTo  string
+	previousTag string
+	historyOpts historyOptions
+	lockJson    bool
+	lockForce   bool
+	logsOpts    logsOptions
 )
 
 type runOptions struct {
 	cmdFromCli []string
+	tag        string
+	watch      bool
 }
 
 func main() {
+	if sandbox.IsInit() {
+		sandbox.Init()
+	}
+
 	rootCmd := &cobra.Command{
 		Use:   "trx",
 		Short: "Runs quorum validation and runs specified command",
@@ -24,19 +43,131 @@ func main() {
 
 By default, it uses the ./trx.yaml configuration file, but you can specify a different path using the --config flag.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := run(runOptions{cmdFromCli: getCommandFromCli(cmd, args)}); err != nil {
+			if err := run(runOptions{cmdFromCli: getCommandFromCli(cmd, args), tag: tag}); err != nil {
 				return err
 			}
 			return nil
 		},
 	}
 
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Periodically checks for a new version and runs specified command",
+		Long: `Keeps running and checks the Git repository for a new version at the specified interval.
+
+Quorum verification and command execution are performed only when a new version is found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return watch(runOptions{cmdFromCli: getCommandFromCli(cmd, args)}, interval)
+		},
+	}
+	watchCmd.Flags().DurationVar(&interval, "interval", time.Minute, "Interval between checks for a new version")
+	watchCmd.Flags().BoolVarP(&disableLock, "disable-lock", "", false, "Disable execution locking")
+	rootCmd.AddCommand(watchCmd)
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Runs quorum validation without executing any command",
+		Long: `Verifies the last semver tag, or the tag specified with the --tag flag, against all configured quorums and prints a report.
+
+Exit codes: 0 — all quorums passed, 1 — quorum verification failed, 2 — verification could not be performed or invalid usage.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return verify(tag, previousTag)
+		},
+	}
+	verifyCmd.Flags().StringVar(&tag, "tag", "", "Tag to verify instead of the last semver tag")
+	verifyCmd.Flags().StringVar(&previousTag, "previous-tag", "", "Last processed tag the bump type of quorums is checked against")
+	rootCmd.AddCommand(verifyCmd)
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Rolls back to a previously processed version",
+		Long: `Runs specified command for the previous successfully processed tag from the history, or for the tag specified with the --to flag.
+
+The tag must pass both the regular quorums and the rollback quorums.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rollback(runOptions{cmdFromCli: getCommandFromCli(cmd, args)}, rollbackTo)
+		},
+	}
+	rollbackCmd.Flags().StringVar(&rollbackTo, "to", "", "Tag to roll back to instead of the previous processed tag")
+	rollbackCmd.Flags().BoolVarP(&disableLock, "disable-lock", "", false, "Disable execution locking")
+	rootCmd.AddCommand(rollbackCmd)
+
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Shows the history of processed runs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showHistory(historyOpts)
+		},
+	}
+	historyCmd.Flags().BoolVar(&historyOpts.asJson, "json", false, "Print history as JSON")
+	historyCmd.Flags().IntVar(&historyOpts.query.Limit, "limit", 20, "Maximum number of the most recent records to show, 0 to show all")
+	historyCmd.Flags().StringVar(&historyOpts.query.Tag, "tag", "", "Show only records for the specified tag")
+	historyCmd.Flags().StringVar(&historyOpts.query.Status, "status", "", "Show only records with the specified status (succeeded, failed, cancelled)")
+	historyCmd.Flags().StringVar(&historyOpts.query.Kind, "kind", "", "Show only records of the specified kind (run, rollback)")
+	rootCmd.AddCommand(historyCmd)
+
+	lockCmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Manages the execution lock",
+	}
+	lockStatusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Shows the execution lock state and holder",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showLockStatus(lockJson)
+		},
+	}
+	lockStatusCmd.Flags().BoolVar(&lockJson, "json", false, "Print lock status as JSON")
+	lockBreakCmd := &cobra.Command{
+		Use:   "break",
+		Short: "Clears a stale execution lock left by a crashed run",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return breakLock(lockForce)
+		},
+	}
+	lockBreakCmd.Flags().BoolVar(&lockForce, "force", false, "Break the lock even if it is held by an active run")
+	lockCmd.AddCommand(lockStatusCmd, lockBreakCmd)
+	rootCmd.AddCommand(lockCmd)
+
+	logsCmd := &cobra.Command{
+		Use:   "logs [runID]",
+		Short: "Shows the logs of a run",
+		Long: `Prints the combined output of the run with the given ID, or lists the runs with stored logs if no ID is given.
+
+Run IDs are shown by the history command.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var runID string
+			if len(args) > 0 {
+				runID = args[0]
+			}
+			return showLogs(runID, logsOpts)
+		},
+	}
+	logsCmd.Flags().StringVar(&logsOpts.step, "step", "", "Print the log of the specified step")
+	logsCmd.Flags().BoolVar(&logsOpts.summary, "summary", false, "Print the run summary")
+	rootCmd.AddCommand(logsCmd)
+
 	rootCmd.SilenceUsage = true
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "./trx.yaml", "Path to config file")
 	rootCmd.Flags().BoolVarP(&force, "force", "f", false, "Force execution if no new version found")
 	rootCmd.Flags().BoolVarP(&disableLock, "disable-lock", "", false, "Disable execution locking")
+	rootCmd.Flags().StringVar(&tag, "tag", "", "Process the specified tag instead of the last semver tag")
 
-	if err := rootCmd.Execute(); err != nil {
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		// Usage errors of verify must not be mistaken for a failed verification.
+		if cmd == verifyCmd {
+			log.Print(err)
+			os.Exit(exitCodeError)
+		}
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+
+	"trx/internal/command"
+	"trx/internal/history"
+)
+
+func rollback(opts runOptions, to string) error {
+	log.SetFlags(0)
+	log.SetOutput(os.Stdout)
+	log.Println("Running trx rollback")
+	log.Printf("Start at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	r, err := newRunner(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(r.cfg.RollbackQuorums) == 0 {
+		return fmt.Errorf("rollback is not allowed: rollbackQuorums are not configured")
+	}
+
+	return r.withLock(func(ctx context.Context) error {
+		return r.rollback(ctx, to)
+	})
+}
+
+func (r *runner) rollback(ctx context.Context, to string) error {
+	cfg := r.cfg
+
+	records, err := r.storage.History(history.Query{})
+	if err != nil {
+		return fmt.Errorf("get history error: %w", err)
+	}
+	currentTag := history.CurrentTag(records)
+	if currentTag == "" {
+		currentTag, err = r.storage.CheckLastSucceedTag()
+		if err != nil {
+			return fmt.Errorf("check last published commit error: %w", err)
+		}
+	}
+	if currentTag == "" {
+		return fmt.Errorf("nothing to roll back: no processed tag found")
+	}
+
+	if to == "" {
+		to, err = history.PreviousTag(records, currentTag)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := validateRollbackTag(to, currentTag, cfg.Repo.InitialLastProcessedTag); err != nil {
+		return err
+	}
+	log.Printf("Rolling back from %s to %s\n", currentTag, to)
+
+	gitTargetObject, err := r.gitClient.GetTargetGitObject(to)
+	if err != nil {
+		return fmt.Errorf("get target git object error: %w", err)
+	}
+	r.locker.SetTag(gitTargetObject.Tag)
+
+	runID := history.NewRunID()
+	vars := generateCmdVars(cfg, gitTargetObject, currentTag, runID)
+	vars["RollbackFromTag"] = currentTag
+	executor, err := command.NewExecutor(ctx, cfg, vars)
+	if err != nil {
+		return fmt.Errorf("command executor error: %w", err)
+	}
+
+	record := history.NewRecord(history.KindRollback, runID, gitTargetObject.Tag, gitTargetObject.Commit)
+	record.FromTag = currentTag
+	executor.Log = r.startRunLog(runID)
+	defer r.stopRunLog(executor.Log, record)
+	err = r.execute(executor, record, currentTag, cfg.Quorums, cfg.RollbackQuorums)
+	if err := r.finishRecord(record, err); err != nil {
+		return err
+	}
+
+	if hookErr := executor.RunOnRollbackHook(cfg); hookErr != nil {
+		log.Printf("WARNING onRollback hook execution error: %s", hookErr.Error())
+	}
+
+	log.Println("All done")
+	return nil
+}
+
+func validateRollbackTag(to, current, initial string) error {
+	toVer, err := semver.NewVersion(to)
+	if err != nil {
+		return fmt.Errorf("invalid rollback tag: %w", err)
+	}
+	currentVer, err := semver.NewVersion(current)
+	if err != nil {
+		return fmt.Errorf("invalid current tag: %w", err)
+	}
+	if !toVer.LessThan(currentVer) {
+		return fmt.Errorf("rollback tag %s must be older than the current tag %s", to, current)
+	}
+
+	if initial != "" {
+		initialVer, err := semver.NewVersion(initial)
+		if err != nil {
+			return fmt.Errorf("invalid initial tag: %w", err)
+		}
+		if toVer.LessThanEqual(initialVer) {
+			return fmt.Errorf("rollback tag %s must be newer than the initial tag %s", to, initial)
+		}
+	}
+	return nil
+}
@@ -11,67 +11,195 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
+
 	"trx/internal/command"
 	"trx/internal/config"
+	"trx/internal/container"
 	"trx/internal/git"
+	"trx/internal/history"
 	"trx/internal/lock"
 	"trx/internal/quorum"
 	"trx/internal/storage"
 )
 
+type runner struct {
+	ctx       context.Context
+	opts      runOptions
+	cfg       *config.Config
+	storage   *storage.StorageService
+	locker    *lock.Manager
+	gitClient *git.GitClient
+}
+
 func run(opts runOptions) error {
 	log.SetFlags(0)
 	log.SetOutput(os.Stdout)
 	log.Println("Running trx")
 	log.Printf("Start at %s\n", time.Now().Format("2006-01-02 15:04:05"))
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := newSignalContext()
 	defer cancel()
 
+	r, err := newRunner(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	return r.run()
+}
+
+func newSignalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-signalChan
+		// A second signal terminates trx at once.
+		signal.Stop(signalChan)
 		log.Printf("Received signal: %s", sig)
 		cancel()
 	}()
 
+	return ctx, cancel
+}
+
+func newRunner(ctx context.Context, opts runOptions) (*runner, error) {
 	cfg, err := config.NewConfig(configPath)
 	if err != nil {
-		return fmt.Errorf("config error: %w", err)
+		return nil, fmt.Errorf("config error: %w", err)
 	}
 
 	storage, err := storage.NewStorage(&storage.StorageOpts{
 		Config: cfg,
 	})
 	if err != nil {
-		return fmt.Errorf("init storage error: %w", err)
+		return nil, fmt.Errorf("init storage error: %w", err)
+	}
+
+	locker, err := lock.NewLocker(cfg.Lock)
+	if err != nil {
+		return nil, fmt.Errorf("init lock error: %w", err)
 	}
 
-	locker := lock.NewManager(lock.NewLocalLocker(disableLock))
-	if err := locker.Acquire(cfg.Repo.Url); err != nil {
+	return &runner{
+		ctx:     ctx,
+		opts:    opts,
+		cfg:     cfg,
+		storage: storage,
+		locker:  lock.NewManager(locker, lock.NewOptions(cfg.Lock, disableLock)),
+	}, nil
+}
+
+func (r *runner) run() error {
+	return r.withLock(r.process)
+}
+
+// withLock runs f holding the execution lock. The context given to f is cancelled if the lock is lost.
+func (r *runner) withLock(f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancelCause(r.ctx)
+	defer cancel(nil)
+	r.locker.SetOnLost(func() {
+		log.Println("Execution lock is lost, cancelling the run")
+		cancel(lock.ErrLost)
+	})
+
+	acquired, err := r.locker.Acquire(r.cfg.Repo.Url, lock.NewHolder(r.opts.tag))
+	if err != nil {
 		return fmt.Errorf("lock acquire error: %w", err)
 	}
+	if !acquired {
+		return nil
+	}
+	defer func() {
+		if err := r.locker.Release(); err != nil {
+			log.Printf("WARNING lock release error: %s", err.Error())
+		}
+	}()
 	if disableLock {
 		log.Println("Processing without execution lock")
 	}
 
-	gitClient, err := git.NewGitClient(cfg.Repo)
+	if err := r.syncRepo(); err != nil {
+		return err
+	}
+
+	if err := r.checkTagCommits(ctx); err != nil {
+		return err
+	}
+
+	err = f(ctx)
+	if cause := context.Cause(ctx); errors.Is(cause, lock.ErrLost) && !errors.Is(err, lock.ErrLost) {
+		if err == nil {
+			return cause
+		}
+		return fmt.Errorf("%w: %w", cause, err)
+	}
+	return err
+}
+
+func (r *runner) syncRepo() error {
+	if r.gitClient != nil {
+		if err := r.gitClient.Fetch(); err != nil {
+			return fmt.Errorf("git fetch error: %w", err)
+		}
+		return nil
+	}
+
+	gitClient, err := git.NewGitClient(r.cfg.Repo)
 	if err != nil {
 		return fmt.Errorf("new git client error: %w", err)
 	}
+	r.gitClient = gitClient
+	return nil
+}
 
-	gitTargetObject, err := gitClient.GetTargetGitObject()
+func (r *runner) checkTagCommits(ctx context.Context) error {
+	tagCommits, err := r.storage.TagCommits()
+	if err != nil {
+		return fmt.Errorf("get processed tag commits error: %w", err)
+	}
+
+	err = r.gitClient.CheckTagCommits(tagCommits)
+	var retargetErr *git.TagRetargetedError
+	if errors.As(err, &retargetErr) {
+		executor, execErr := command.NewExecutor(ctx, r.cfg, map[string]any{
+			"RepoUrl":        r.cfg.Repo.Url,
+			"RepoTag":        retargetErr.Tag,
+			"RepoCommit":     retargetErr.ActualCommit,
+			"ExpectedCommit": retargetErr.ExpectedCommit,
+		})
+		if execErr != nil {
+			return fmt.Errorf("command executor error: %w", execErr)
+		}
+		if hookErr := executor.RunOnTagRetargetedHook(r.cfg); hookErr != nil {
+			log.Printf("WARNING onTagRetargeted hook execution error: %s", hookErr.Error())
+		}
+		return fmt.Errorf("security error: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("check processed tag commits error: %w", err)
+	}
+	return nil
+}
+
+func (r *runner) process(ctx context.Context) error {
+	cfg := r.cfg
+
+	gitTargetObject, err := r.gitClient.GetTargetGitObject(r.opts.tag)
 	if err != nil {
 		return fmt.Errorf("get target git object error: %w", err)
 	}
+	r.locker.SetTag(gitTargetObject.Tag)
 
-	lastSucceedTag, err := storage.CheckLastSucceedTag()
+	lastSucceedTag, err := r.storage.CheckLastSucceedTag()
 	if err != nil {
 		return fmt.Errorf("check last published commit error: %w", err)
 	}
 
-	executor, err := command.NewExecutor(ctx, cfg.Env, generateCmdVars(cfg, gitTargetObject))
+	runID := history.NewRunID()
+	executor, err := command.NewExecutor(ctx, cfg, generateCmdVars(cfg, gitTargetObject, lastSucceedTag, runID))
 	if err != nil {
 		return fmt.Errorf("command executor error: %w", err)
 	}
@@ -80,85 +208,216 @@ func run(opts runOptions) error {
 	if err != nil {
 		return fmt.Errorf("can't check if tag is new: %w", err)
 	}
+	previousTag := lastSucceedTag
+	if previousTag == "" {
+		previousTag = cfg.Repo.InitialLastProcessedTag
+	}
 	if !isNewVersion {
-		switch force {
-		case true:
+		switch {
+		case force:
+			if err := validateForcedTag(gitTargetObject.Tag, previousTag); err != nil {
+				return err
+			}
 			log.Println("No new version, but force flag specified. Proceeding... ")
-		case false:
+		case r.opts.watch:
+			log.Println("No new version. execution will be skipped")
+			return nil
+		default:
 			if hookErr := executor.RunOnCommandSkippedHook(cfg); hookErr != nil {
 				log.Println("WARNING onCommandSkipped hook execution error: %w", hookErr)
 			}
+			if r.opts.tag != "" {
+				log.Printf("Tag %s is not newer than the last processed tag. Use the force flag to process it anyway", r.opts.tag)
+			}
 			log.Println("No new version. execution will be skipped")
 			return nil
 		}
 	}
 
-	err = quorum.CheckQuorums(cfg.Quorums, gitClient.Repo, gitTargetObject.Tag)
+	record := history.NewRecord(history.KindRun, runID, gitTargetObject.Tag, gitTargetObject.Commit)
+	executor.Log = r.startRunLog(runID)
+	defer r.stopRunLog(executor.Log, record)
+	err = r.execute(executor, record, previousTag, cfg.Quorums)
+	if cause := context.Cause(ctx); err == nil && errors.Is(cause, lock.ErrLost) {
+		// Another run may have taken the lock, so the tag is not recorded as processed.
+		err = cause
+	}
+	if err == nil {
+		if err = r.storage.StoreSucceedTag(gitTargetObject.Tag, gitTargetObject.Commit); err != nil {
+			err = fmt.Errorf("store last successed tag error: %w", err)
+		}
+	}
+	if err := r.finishRecord(record, err); err != nil {
+		return err
+	}
+
+	if hookErr := executor.RunOnCommandSuccessHook(cfg); hookErr != nil {
+		log.Println("WARNING onCommandSuccess hook execution error: %w", hookErr)
+	}
+
+	log.Println("All done")
+	return nil
+}
+
+// validateForcedTag refuses to force a tag older than the previous one. A downgrade has to pass the rollback
+// quorums and must not move the last processed tag backwards, so it is only allowed with `trx rollback`.
+func validateForcedTag(tag, previousTag string) error {
+	if previousTag == "" {
+		return nil
+	}
+	ver, err := semver.NewVersion(tag)
 	if err != nil {
-		var qErr *quorum.Error
-		if errors.As(err, &qErr) {
-			executor.Vars["FailedQuorumName"] = qErr.QuorumName
-			if hookErr := executor.RunOnQuorumFailedHook(cfg); hookErr != nil {
-				log.Println("WARNING onCommandSkipped hook execution error: %w", hookErr)
+		return fmt.Errorf("invalid tag: %w", err)
+	}
+	previousVer, err := semver.NewVersion(previousTag)
+	if err != nil {
+		return fmt.Errorf("invalid last processed tag: %w", err)
+	}
+	if ver.LessThan(previousVer) {
+		return fmt.Errorf("tag %s is older than the last processed tag %s, use `trx rollback --to %s` to roll back", tag, previousTag, tag)
+	}
+	return nil
+}
+
+// execute verifies the target tag against all given quorum sets and runs the commands, filling the history record.
+// The previous tag selects the quorums by bump type.
+func (r *runner) execute(executor *command.Executor, record *history.Record, previousTag string, quorumSets ...[]config.Quorum) error {
+	cfg := r.cfg
+
+	signers := make(map[string][]string)
+	executor.Vars["Signers"] = signers
+	for _, quorums := range quorumSets {
+		results, err := quorum.CheckQuorums(quorums, r.gitClient.Repo, record.Tag, previousTag)
+		for _, res := range results {
+			record.Quorums = append(record.Quorums, history.QuorumRecord{
+				Name:    res.QuorumName,
+				Passed:  res.Passed(),
+				Signers: res.Signers,
+			})
+			signers[res.QuorumName] = res.Signers
+		}
+		if err != nil {
+			var qErr *quorum.Error
+			if errors.As(err, &qErr) {
+				executor.Vars["FailedQuorumName"] = qErr.QuorumName
+				if hookErr := executor.RunOnQuorumFailedHook(cfg); hookErr != nil {
+					log.Printf("WARNING onQuorumFailure hook execution error: %s", hookErr.Error())
+				}
+				return fmt.Errorf("quorum error: %w", qErr.Err)
+			} else {
+				return fmt.Errorf("quorum error: %w", err)
 			}
-			return fmt.Errorf("quorum error: %w", qErr.Err)
-		} else {
-			return fmt.Errorf("quorum error: %w", err)
 		}
 	}
 
-	cmdsToRun, err := getCmdsToRun(cfg, opts, executor)
+	cmdsToRun, err := getCmdsToRun(cfg, r.opts, executor)
 	if err != nil {
 		return fmt.Errorf("get commands to run error: %w", err)
 	}
+	for _, step := range cmdsToRun {
+		record.Commands = append(record.Commands, step.Run.String())
+	}
 
 	// TODO: think about running this hook concurrently with the command
 	if hookErr := executor.RunOnCommandStartedHook(cfg); hookErr != nil {
 		log.Printf("WARNING onCommandStarted hook execution error: %s", hookErr.Error())
 	}
 
-	if err := executor.Exec(cmdsToRun); err != nil {
+	results, err := executor.ExecSteps(cmdsToRun)
+	for _, res := range results {
+		if res.Skipped {
+			record.Steps = append(record.Steps, history.NewSkippedStepRecord(res.Name))
+			continue
+		}
+		record.Steps = append(record.Steps, history.NewStepRecord(res.Name, res.StartedAt, res.FinishedAt, res.Attempts, res.Err))
+	}
+	if err != nil {
+		var stepErr *command.StepError
+		if errors.As(err, &stepErr) {
+			executor.Vars["FailedStepName"] = stepErr.Step
+		}
+		if errors.Is(err, context.Canceled) {
+			if hookErr := executor.RunOnCommandCancelledHook(cfg); hookErr != nil {
+				log.Printf("WARNING onCommandCancelled hook execution error: %s", hookErr.Error())
+			}
+			return fmt.Errorf("run command cancelled: %w", err)
+		}
 		if hookErr := executor.RunOnCommandFailureHook(cfg); hookErr != nil {
 			log.Println("WARNING onCommandFailure hook execution error: %w", hookErr)
 		}
 		return fmt.Errorf("run command error: %w", err)
 	}
 
-	if err := storage.StoreSucceedTag(gitTargetObject.Tag); err != nil {
-		return fmt.Errorf("store last successed tag error: %w", err)
-	}
+	return nil
+}
 
-	if hookErr := executor.RunOnCommandSuccessHook(cfg); hookErr != nil {
-		log.Println("WARNING onCommandSuccess hook execution error: %w", hookErr)
+func (r *runner) finishRecord(record *history.Record, runErr error) error {
+	record.Finish(runErr)
+	if err := r.storage.StoreRecord(*record); err != nil {
+		if runErr != nil {
+			log.Printf("WARNING store history record error: %s", err.Error())
+			return runErr
+		}
+		return fmt.Errorf("store history record error: %w", err)
 	}
-
-	log.Println("All done")
-	return nil
+	return runErr
 }
 
-func generateCmdVars(cfg *config.Config, t *git.TargetGitObject) map[string]string {
-	vars := make(map[string]string)
+func generateCmdVars(cfg *config.Config, t *git.TargetGitObject, previousTag, runID string) map[string]any {
+	vars := make(map[string]any)
+	vars["RunID"] = runID
 	vars["RepoTag"] = t.Tag
 	vars["RepoUrl"] = cfg.Repo.Url
 	vars["RepoCommit"] = t.Commit
+	vars["ShortCommit"] = command.ShortSha(t.Commit)
+	vars["PreviousTag"] = previousTag
+	vars["TagMessage"] = t.TagMessage
+	vars["TagDate"] = t.TagDate.Format(time.RFC3339)
+	vars["CommitAuthor"] = t.CommitAuthor
+	vars["CommitMessage"] = t.CommitMessage
+	vars["Signers"] = map[string][]string{}
+
+	var major, minor, patch uint64
+	var prerelease string
+	if v, err := semver.NewVersion(t.Tag); err == nil {
+		major, minor, patch, prerelease = v.Major(), v.Minor(), v.Patch(), v.Prerelease()
+	}
+	vars["TagMajor"] = major
+	vars["TagMinor"] = minor
+	vars["TagPatch"] = patch
+	vars["TagPrerelease"] = prerelease
 	return vars
 }
 
-func mergeEnvs(envs, cfgEnv map[string]string) []string {
+func mergeEnvs(envs, cfgEnv map[string]config.EnvVar) map[string]config.EnvVar {
+	merged := make(map[string]config.EnvVar, len(envs)+len(cfgEnv))
+	for k, v := range envs {
+		merged[k] = v
+	}
 	for k, v := range cfgEnv {
-		envs[k] = v
+		merged[k] = v
 	}
-	newEnv := make([]string, 0, len(envs))
-	for k, v := range envs {
-		newEnv = append(newEnv, fmt.Sprintf("%s=%s", k, v))
+	return merged
+}
+
+// setImage makes the executor run the steps in the image with the configured container runtime.
+func setImage(cfg *config.Config, executor *command.Executor, image string) error {
+	if cfg.Sandbox != nil {
+		return fmt.Errorf("sandbox can't be used with the image %s", image)
+	}
+	runtime, err := container.NewRuntime(cfg.ContainerRuntime)
+	if err != nil {
+		return err
 	}
-	return newEnv
+	log.Printf("Running commands in image %s\n", image)
+	executor.Image, executor.Runtime = image, runtime
+	return nil
 }
 
-func getCmdsToRun(cfg *config.Config, opts runOptions, executor *command.Executor) ([]string, error) {
-	var cmdsToRun []string
+func getCmdsToRun(cfg *config.Config, opts runOptions, executor *command.Executor) ([]config.Step, error) {
+	var cmdsToRun []config.Step
 	if len(opts.cmdFromCli) > 0 {
-		cmdsToRun = []string{strings.Join(opts.cmdFromCli, " ")}
+		cmdsToRun = []config.Step{config.NewInlineStep(strings.Join(opts.cmdFromCli, " "))}
 		return cmdsToRun, nil
 	}
 
@@ -170,7 +429,14 @@ func getCmdsToRun(cfg *config.Config, opts runOptions, executor *command.Executo
 			return nil, fmt.Errorf("config error: %w", err)
 		}
 		cmdsToRun = runCfg.Commands
-		executor.Env = mergeEnvs(cfg.Env, runCfg.Env)
+		executor.SetEnv(mergeEnvs(cfg.Env, runCfg.Env))
+		executor.EnvPolicy = cfg.EnvPolicy.Restrict(runCfg.EnvPolicy)
+		executor.MaxParallel = command.MaxParallel(cfg.MaxParallel, runCfg.MaxParallel)
+		if runCfg.Image != "" {
+			if err := setImage(cfg, executor, runCfg.Image); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	if len(cmdsToRun) == 0 {
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"trx/internal/config"
+	"trx/internal/git"
+	"trx/internal/quorum"
+)
+
+const (
+	exitCodeVerificationFailed = 1
+	exitCodeError              = 2
+)
+
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func verify(tag, previousTag string) error {
+	log.SetFlags(0)
+	log.SetOutput(os.Stderr)
+
+	cfg, err := config.NewConfig(configPath)
+	if err != nil {
+		return &exitError{code: exitCodeError, err: fmt.Errorf("config error: %w", err)}
+	}
+
+	gitClient, err := git.NewGitClient(cfg.Repo)
+	if err != nil {
+		return &exitError{code: exitCodeError, err: fmt.Errorf("new git client error: %w", err)}
+	}
+
+	target, err := gitClient.ResolveTargetGitObject(tag)
+	if err != nil {
+		return &exitError{code: exitCodeError, err: fmt.Errorf("get target git object error: %w", err)}
+	}
+
+	if previousTag == "" {
+		previousTag = cfg.Repo.InitialLastProcessedTag
+	}
+	results, err := quorum.CheckQuorums(cfg.Quorums, gitClient.Repo, target.Tag, previousTag)
+	if printErr := printVerifyReport(os.Stdout, target, results); printErr != nil {
+		return &exitError{code: exitCodeError, err: printErr}
+	}
+	if err != nil {
+		return &exitError{code: exitCodeVerificationFailed, err: fmt.Errorf("quorum error: %w", err)}
+	}
+
+	return nil
+}
+
+func printVerifyReport(out io.Writer, target *git.TargetGitObject, results []quorum.Result) error {
+	fmt.Fprintf(out, "Tag: %s\nCommit: %s\n\n", target.Tag, target.Commit)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "QUORUM\tREQUIRED\tSIGNED\tSTATUS\tSIGNERS")
+	for _, r := range results {
+		status := "PASS"
+		if !r.Passed() {
+			status = "FAIL"
+		}
+		signers := "-"
+		if len(r.Signers) > 0 {
+			signers = strings.Join(r.Signers, ",")
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", r.QuorumName, r.MinNumberOfKeys, len(r.Signers), status, signers)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if !r.Passed() {
+			fmt.Fprintf(out, "\nquorum `%s` error: %s\n", r.QuorumName, r.Err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func watch(opts runOptions, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval must be greater than zero")
+	}
+
+	log.SetFlags(0)
+	log.SetOutput(os.Stdout)
+	log.Printf("Running trx in watch mode with interval %s\n", interval)
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	opts.watch = true
+	r, err := newRunner(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping watch")
+			return nil
+		case <-timer.C:
+		}
+
+		log.Printf("Start at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+		if err := r.run(); err != nil {
+			log.Printf("ERROR %s", err.Error())
+		}
+		timer.Reset(interval)
+	}
+}
@@ -1,20 +1,27 @@
 package command
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"fmt"
-	"html/template"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"strings"
+	"text/template"
+	"time"
+
+	"trx/internal/config"
+	"trx/internal/container"
+	"trx/internal/runlog"
+	"trx/internal/sandbox"
 )
 
 var WorkDir = ""
 
+// DefaultGracePeriod is the time between SIGTERM and SIGKILL sent to cancelled commands.
+const DefaultGracePeriod = 10 * time.Second
+
 type Vars struct {
 	RepoUrl string
 	RepoTag string
@@ -24,116 +31,216 @@ type Executor struct {
 	Ctx     context.Context
 	WorkDir string
 	Env     []string
-	Vars    map[string]string
+	Vars    map[string]any
+	// Masker masks the secrets in the output and errors of the commands.
+	Masker *Masker
+	// EnvPolicy selects the variables of the trx process passed to the commands and available in the templates.
+	EnvPolicy *config.EnvPolicy
+	// GracePeriod is the time cancelled commands get to exit after SIGTERM before they are killed.
+	GracePeriod time.Duration
+	// Sandbox restricts the steps. Hooks are run without the sandbox.
+	Sandbox *sandbox.Spec
+	// MaxParallel is the maximum number of steps run at once.
+	MaxParallel int
+	// Image is the image the steps are run in with the Runtime. The steps are run on the host if it is empty.
+	Image   string
+	Runtime container.Runtime
+
+	// secretEnv holds the names of the secret env variables, their values are masked once resolved.
+	secretEnv map[string]bool
+
+	// Log is the directory of the current run, the step logs and commands are written to it if set.
+	Log *runlog.Run
 }
 
-func NewExecutor(ctx context.Context, e, vars map[string]string) (*Executor, error) {
+func NewExecutor(ctx context.Context, cfg *config.Config, vars map[string]any) (*Executor, error) {
 	wd := WorkDir
 	if wd == "" {
 		wd, _ = os.Getwd()
 	}
-	var envs []string
-	for k, v := range e {
-		envs = append(envs, fmt.Sprintf("%s=%s", strings.ToUpper(k), v))
+	executor := &Executor{
+		Ctx:         ctx,
+		WorkDir:     wd,
+		Vars:        vars,
+		Masker:      NewMasker(cfg.Secrets()...),
+		EnvPolicy:   cfg.EnvPolicy,
+		GracePeriod: cfg.TerminationGracePeriod,
+		MaxParallel: MaxParallel(cfg.MaxParallel, 0),
+	}
+	if executor.GracePeriod == 0 {
+		executor.GracePeriod = DefaultGracePeriod
+	}
+	spec, err := sandbox.NewSpec(cfg.Sandbox, wd)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox error: %w", err)
+	}
+	executor.Sandbox = spec
+	executor.SetEnv(cfg.Env)
+	return executor, nil
+}
+
+// SetEnv replaces the env of the commands.
+func (e *Executor) SetEnv(env map[string]config.EnvVar) {
+	e.Env = nil
+	e.secretEnv = make(map[string]bool)
+	for k, v := range env {
+		k = strings.ToUpper(k)
+		e.Env = append(e.Env, fmt.Sprintf("%s=%s", k, v.Value))
+		if v.Secret {
+			e.secretEnv[k] = true
+		}
 	}
-	return &Executor{
-		Ctx:     ctx,
-		WorkDir: wd,
-		Env:     envs,
-		Vars:    vars,
-	}, nil
 }
 
 func (e *Executor) Exec(commands []string) error {
-	cmds, err := resolve(commands, e.Vars)
+	return e.Masker.MaskError(e.exec(commands))
+}
+
+func (e *Executor) exec(commands []string) error {
+	cmds, err := e.resolve(commands)
 	if err != nil {
 		return fmt.Errorf("can't resolve commands: %w", err)
 	}
-	envs, err := resolve(e.Env, e.Vars)
+	envs, err := e.resolveEnv(e.Env, nil)
 	if err != nil {
 		return fmt.Errorf("can't resolve envs: %w", err)
 	}
 	script := "set -e\n" + strings.Join(cmds, "\n")
 	if err := execute(e.Ctx, &excuteOpts{
-		cmd: script,
-		env: envs,
-		wd:  e.WorkDir,
+		args:        []string{"sh", "-c", script},
+		hostEnv:     e.hostEnv(),
+		env:         envs,
+		wd:          e.WorkDir,
+		masker:      e.Masker,
+		gracePeriod: e.GracePeriod,
 	}); err != nil {
 		return fmt.Errorf("executor error: %w", err)
 	}
 	return nil
 }
 
-func resolve(commands []string, vars map[string]string) ([]string, error) {
-	resolved := make([]string, len(commands))
-	for i, cmd := range commands {
-		resCmd, err := resolveTemplate(cmd, vars)
-		if err != nil {
-			return nil, err
+// resolveEnv resolves the env templates and registers the values of the secret variables in the masker.
+// The step secret variables are given in addition to the executor ones.
+func (e *Executor) resolveEnv(envs []string, secretEnv map[string]bool) ([]string, error) {
+	resolved, err := e.resolve(envs)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range resolved {
+		k, v, _ := strings.Cut(env, "=")
+		if e.secretEnv[k] || secretEnv[k] {
+			e.Masker.Add(v)
 		}
-		resolved[i] = resCmd
 	}
 	return resolved, nil
 }
 
-func resolveTemplate(tmpl string, vars map[string]string) (string, error) {
-	t, err := template.New("cmd").Parse(tmpl)
-	if err != nil {
-		return "", err
+// hostEnv returns the variables of the trx process allowed by the env policy.
+func (e *Executor) hostEnv() []string {
+	return e.EnvPolicy.Filter(os.Environ())
+}
+
+// lookupEnv is the `env` template function, it only reads the variables allowed by the env policy.
+func (e *Executor) lookupEnv(name string) string {
+	if !e.EnvPolicy.Allows(name) {
+		return ""
 	}
+	return os.Getenv(name)
+}
 
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, vars); err != nil {
-		return "", err
+func (e *Executor) resolve(templates []string) ([]string, error) {
+	funcs := e.templateFuncs()
+	resolved := make([]string, len(templates))
+	for i, tmpl := range templates {
+		res, err := resolveTemplate(tmpl, e.Vars, funcs)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = res
 	}
+	return resolved, nil
+}
+
+func (e *Executor) resolveTemplate(tmpl string) (string, error) {
+	return resolveTemplate(tmpl, e.Vars, e.templateFuncs())
+}
 
-	return buf.String(), nil
+func (e *Executor) templateFuncs() template.FuncMap {
+	return newTemplateFuncs(e.lookupEnv)
 }
 
 type excuteOpts struct {
-	cmd string
-	env []string
-	wd  string
+	args []string
+	// hostEnv are the variables of the trx process passed to the command.
+	hostEnv []string
+	env     []string
+	wd      string
+	// output receives the output lines in addition to the log.
+	output io.Writer
+	masker *Masker
+	// prefix is the name of the step prepended to the output lines.
+	prefix string
+	// gracePeriod is the time between SIGTERM and SIGKILL sent to the process group on cancellation.
+	gracePeriod time.Duration
+	sandbox     *sandbox.Spec
+	// stopContainer kills the container the command is run in, as killing the runtime client leaves it running.
+	stopContainer func() error
 }
 
 func execute(ctx context.Context, opts *excuteOpts) error {
-	cmd := exec.CommandContext(ctx, "sh", "-c", opts.cmd)
+	cmd := exec.CommandContext(ctx, opts.args[0], opts.args[1:]...)
 	cmd.Dir = opts.wd
-	cmd.Env = append(os.Environ(), opts.env...)
+	cmd.Env = append(append([]string{}, opts.hostEnv...), opts.env...)
 
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	exited := make(chan struct{})
+	defer close(exited)
+	setProcessGroup(cmd)
+	if err := opts.sandbox.Wrap(cmd); err != nil {
+		return fmt.Errorf("sandbox error: %w", err)
 	}
-	defer stdoutPipe.Close()
-
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	kill := func() error {
+		// The container is killed first, so it is gone once the runtime client exits.
+		if opts.stopContainer != nil {
+			if err := opts.stopContainer(); err != nil {
+				log.Printf("WARNING unable to kill container: %s", err.Error())
+			}
+		}
+		return killProcessGroup(cmd)
 	}
-	defer stderrPipe.Close()
-
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("error starting command: %w", err)
+	cmd.Cancel = func() error {
+		if opts.gracePeriod <= 0 {
+			return kill()
+		}
+		log.Printf("Terminating command, it is killed if still running in %s\n", opts.gracePeriod)
+		err := terminateProcessGroup(cmd)
+		go func() {
+			time.Sleep(opts.gracePeriod)
+			select {
+			case <-exited:
+				// The children ignoring SIGTERM may still run in the group after the command process exited.
+				_ = killProcessGroup(cmd)
+			default:
+				_ = kill()
+			}
+		}()
+		return err
 	}
 
-	go func() {
-		scanner := bufio.NewScanner(stdoutPipe)
-		for scanner.Scan() {
-			log.Println(scanner.Text())
-		}
-	}()
+	stdout := newLineWriter(opts.prefix, "stdout", opts.output, opts.masker, 0)
+	stderr := newLineWriter(opts.prefix, "stderr", opts.output, opts.masker, stderrTailLines)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
-	var stderr bytes.Buffer
-	if _, err := io.Copy(&stderr, stderrPipe); err != nil {
-		log.Printf("error write stderr buffer: %s", err.Error())
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting command: %w", err)
 	}
 
-	if err := cmd.Wait(); err != nil {
-		if stderr.Len() > 0 {
-			log.Println("executing error:", stderr.String())
-		}
-		return fmt.Errorf("error executing command: %w", err)
+	// Wait returns after the output is copied to the writers.
+	err := cmd.Wait()
+	stdout.Flush()
+	stderr.Flush()
+	if err != nil {
+		return &ExecError{Err: err, StderrTail: stderr.Tail()}
 	}
 	return nil
 }
@@ -1,11 +1,15 @@
 package command
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"trx/internal/config"
 )
 
+const cancelledHookTimeout = time.Minute
+
 func (e *Executor) RunOnCommandStartedHook(cfg *config.Config) error {
 	if cfg.Hooks != nil && cfg.Hooks.OnCommandStarted != nil {
 		log.Println("Running onStartedSuccess hook")
@@ -55,3 +59,38 @@ func (e *Executor) RunOnQuorumFailedHook(cfg *config.Config) error {
 	}
 	return nil
 }
+
+func (e *Executor) RunOnRollbackHook(cfg *config.Config) error {
+	if cfg.Hooks != nil && cfg.Hooks.OnRollback != nil {
+		log.Println("Running onRollback hook")
+		if err := e.Exec(*cfg.Hooks.OnRollback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Executor) RunOnTagRetargetedHook(cfg *config.Config) error {
+	if cfg.Hooks != nil && cfg.Hooks.OnTagRetargeted != nil {
+		log.Println("Running onTagRetargeted hook")
+		if err := e.Exec(*cfg.Hooks.OnTagRetargeted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunOnCommandCancelledHook runs the hook with a context detached from the cancelled run, limited by cancelledHookTimeout.
+func (e *Executor) RunOnCommandCancelledHook(cfg *config.Config) error {
+	if cfg.Hooks != nil && cfg.Hooks.OnCommandCancelled != nil {
+		log.Println("Running onCommandCancelled hook")
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(e.Ctx), cancelledHookTimeout)
+		defer cancel()
+		hookExecutor := *e
+		hookExecutor.Ctx = ctx
+		if err := hookExecutor.Exec(*cfg.Hooks.OnCommandCancelled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
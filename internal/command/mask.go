@@ -0,0 +1,90 @@
+package command
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const maskedValue = "***"
+
+// Masker replaces known secret values with `***`.
+type Masker struct {
+	mu       sync.RWMutex
+	secrets  map[string]struct{}
+	replacer *strings.Replacer
+}
+
+func NewMasker(secrets ...string) *Masker {
+	m := &Masker{secrets: make(map[string]struct{})}
+	m.Add(secrets...)
+	return m
+}
+
+// Add registers secret values. Every line of a multiline secret is masked separately, since the output is masked line by line.
+func (m *Masker) Add(secrets ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changed := false
+	for _, secret := range secrets {
+		for _, s := range append(strings.Split(secret, "\n"), secret) {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			if _, ok := m.secrets[s]; !ok {
+				m.secrets[s] = struct{}{}
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return
+	}
+
+	// Longer secrets go first, so a secret containing another one is masked entirely.
+	values := make([]string, 0, len(m.secrets))
+	for s := range m.secrets {
+		values = append(values, s)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	oldnew := make([]string, 0, 2*len(values))
+	for _, s := range values {
+		oldnew = append(oldnew, s, maskedValue)
+	}
+	m.replacer = strings.NewReplacer(oldnew...)
+}
+
+func (m *Masker) Mask(s string) string {
+	if m == nil {
+		return s
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.replacer == nil {
+		return s
+	}
+	return m.replacer.Replace(s)
+}
+
+// MaskError returns the error with the secrets masked in its message. The original error is kept for errors.Is and errors.As.
+func (m *Masker) MaskError(err error) error {
+	if err == nil || m == nil {
+		return err
+	}
+	return &maskedError{err: err, msg: m.Mask(err.Error())}
+}
+
+type maskedError struct {
+	err error
+	msg string
+}
+
+func (e *maskedError) Error() string {
+	return e.msg
+}
+
+func (e *maskedError) Unwrap() error {
+	return e.err
+}
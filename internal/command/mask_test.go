@@ -0,0 +1,62 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trx/internal/config"
+)
+
+func TestMasker(t *testing.T) {
+	m := NewMasker("", "pass", "password123", "line1\nline2")
+	assert.Equal(t, "*** and *** and *** ***", m.Mask("password123 and pass and line1 line2"))
+
+	m.Add("token")
+	assert.Equal(t, "a *** b", m.Mask("a token b"))
+
+	var nilMasker *Masker
+	assert.Equal(t, "token", nilMasker.Mask("token"))
+}
+
+func TestMasker_MaskError(t *testing.T) {
+	m := NewMasker("s3cr3t")
+	stepErr := &StepError{Step: "deploy", Err: errors.New("auth failed with s3cr3t")}
+	err := m.MaskError(stepErr)
+
+	assert.Equal(t, "step deploy failed: auth failed with ***", err.Error())
+	var target *StepError
+	require.True(t, errors.As(err, &target))
+	assert.Equal(t, "deploy", target.Step)
+}
+
+func TestExecSteps_MasksSecrets(t *testing.T) {
+	buf := captureLog(t)
+
+	e, err := NewExecutor(context.Background(), &config.Config{
+		Repo: config.GitRepo{Auth: config.GitRepoAuth{SshKeyPassword: "hunter2"}},
+		Env: map[string]config.EnvVar{
+			"api_token": {Value: "{{ .RepoTag }}-token", Secret: true},
+			"region":    {Value: "eu-west"},
+		},
+	}, map[string]any{"RepoTag": "v1.0.0"})
+	require.NoError(t, err)
+	e.WorkDir = t.TempDir()
+
+	_, err = e.ExecSteps([]config.Step{
+		config.NewInlineStep(`echo "$API_TOKEN $REGION"; echo hunter2 >&2; exit 1`),
+	})
+	require.Error(t, err)
+
+	assert.Contains(t, buf.String(), "[stdout] *** eu-west")
+	assert.Contains(t, buf.String(), "[stderr] ***")
+	assert.NotContains(t, buf.String(), "v1.0.0-token")
+	assert.NotContains(t, err.Error(), "hunter2")
+	assert.Contains(t, err.Error(), "***")
+
+	require.Error(t, e.Exec([]string{`echo "hunter2 $API_TOKEN"`, "exit 1"}))
+	assert.NotContains(t, buf.String(), "hunter2")
+}
@@ -0,0 +1,99 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+const stderrTailLines = 20
+
+// ExecError is a command failure with the last lines of its stderr.
+type ExecError struct {
+	Err        error
+	StderrTail []string
+}
+
+func (e *ExecError) Error() string {
+	if len(e.StderrTail) == 0 {
+		return fmt.Sprintf("error executing command: %s", e.Err.Error())
+	}
+	return fmt.Sprintf("error executing command: %s\n%s", e.Err.Error(), strings.Join(e.StderrTail, "\n"))
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// lineWriter logs the command output line by line as it arrives, prefixing every line with the step and stream names.
+// Secrets are masked before a line is written anywhere. It keeps the last lines if the tail size is set.
+type lineWriter struct {
+	prefix   string
+	stream   string
+	output   io.Writer
+	masker   *Masker
+	tailSize int
+
+	mu   sync.Mutex
+	buf  []byte
+	tail []string
+}
+
+func newLineWriter(prefix, stream string, output io.Writer, masker *Masker, tailSize int) *lineWriter {
+	return &lineWriter{prefix: prefix, stream: stream, output: output, masker: masker, tailSize: tailSize}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line if it is not terminated with a newline.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.writeLine(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) Tail() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.tail...)
+}
+
+func (w *lineWriter) writeLine(line string) {
+	line = w.masker.Mask(strings.TrimSuffix(line, "\r"))
+	if w.prefix != "" {
+		log.Printf("[%s] [%s] %s", w.prefix, w.stream, line)
+	} else {
+		log.Printf("[%s] %s", w.stream, line)
+	}
+	if w.output != nil {
+		_, _ = fmt.Fprintf(w.output, "[%s] %s\n", w.stream, line)
+	}
+
+	if w.tailSize > 0 {
+		w.tail = append(w.tail, line)
+		if len(w.tail) > w.tailSize {
+			w.tail = w.tail[len(w.tail)-w.tailSize:]
+		}
+	}
+}
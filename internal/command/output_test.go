@@ -0,0 +1,51 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	flags, out := log.Flags(), log.Writer()
+	log.SetFlags(0)
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		log.SetFlags(flags)
+		log.SetOutput(out)
+	})
+	return &buf
+}
+
+func TestExecute_StreamsOutput(t *testing.T) {
+	buf := captureLog(t)
+
+	err := execute(context.Background(), &excuteOpts{
+		args: []string{"sh", "-c", "echo out1; sleep 0.1; echo err1 >&2; sleep 0.1; echo out2; sleep 0.1; printf partial >&2"},
+		wd:   t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"[stdout] out1", "[stderr] err1", "[stdout] out2", "[stderr] partial"}, strings.Split(strings.TrimSpace(buf.String()), "\n"))
+}
+
+func TestExecute_StderrTail(t *testing.T) {
+	captureLog(t)
+
+	err := execute(context.Background(), &excuteOpts{
+		args: []string{"sh", "-c", "for i in $(seq 1 30); do echo line$i >&2; done; exit 4"},
+		wd:   t.TempDir(),
+	})
+	var execErr *ExecError
+	require.True(t, errors.As(err, &execErr))
+	assert.Len(t, execErr.StderrTail, stderrTailLines)
+	assert.Equal(t, "line30", execErr.StderrTail[stderrTailLines-1])
+	assert.Contains(t, err.Error(), "exit status 4\nline11\n")
+}
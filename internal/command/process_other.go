@@ -0,0 +1,18 @@
+//go:build !unix
+
+package command
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing, process groups are only supported on unix. Only the command process is killed.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package command
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in its own process group, so it can be terminated with all its children.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return signalProcessGroup(cmd, syscall.SIGTERM)
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	return signalProcessGroup(cmd, syscall.SIGKILL)
+}
+
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	err := syscall.Kill(-cmd.Process.Pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
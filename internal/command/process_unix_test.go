@@ -0,0 +1,109 @@
+//go:build unix
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecute_CancelTerminatesProcessGroup(t *testing.T) {
+	captureLog(t)
+	wd := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		cancel()
+	}()
+
+	err := execute(ctx, &excuteOpts{
+		args:        []string{"sh", "-c", "trap 'echo cleanup > cleanup; exit 1' TERM; sleep 30 & echo $! > child; wait"},
+		wd:          wd,
+		gracePeriod: 5 * time.Second,
+	})
+	require.Error(t, err)
+
+	cleanup, err := os.ReadFile(filepath.Join(wd, "cleanup"))
+	require.NoError(t, err)
+	assert.Equal(t, "cleanup\n", string(cleanup))
+
+	data, err := os.ReadFile(filepath.Join(wd, "child"))
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return !processRunning(pid)
+	}, time.Second, 10*time.Millisecond, "the child process must be terminated with the group")
+}
+
+func TestExecute_CancelKillsDetachedChildAfterGracePeriod(t *testing.T) {
+	captureLog(t)
+	wd := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		cancel()
+	}()
+
+	// The child ignores SIGTERM and doesn't hold the output pipes, so the command returns before it exits.
+	err := execute(ctx, &excuteOpts{
+		args:        []string{"sh", "-c", "sh -c 'trap \"\" TERM; sleep 30' >/dev/null 2>&1 & echo $! > child; wait"},
+		wd:          wd,
+		gracePeriod: 500 * time.Millisecond,
+	})
+	require.Error(t, err)
+
+	data, err := os.ReadFile(filepath.Join(wd, "child"))
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	assert.True(t, processRunning(pid), "the child ignoring SIGTERM must survive until the grace period ends")
+	assert.Eventually(t, func() bool {
+		return !processRunning(pid)
+	}, 2*time.Second, 10*time.Millisecond, "the child must be killed when the grace period ends")
+}
+
+// processRunning reports whether the process exists and is not a zombie waiting for an orphan reaper.
+func processRunning(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+func TestExecute_CancelKillsAfterGracePeriod(t *testing.T) {
+	captureLog(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := execute(ctx, &excuteOpts{
+		args:        []string{"sh", "-c", "trap '' TERM; sleep 10"},
+		wd:          t.TempDir(),
+		gracePeriod: 200 * time.Millisecond,
+	})
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
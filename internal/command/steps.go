@@ -0,0 +1,387 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"trx/internal/config"
+	"trx/internal/container"
+	"trx/internal/runlog"
+)
+
+// DefaultMaxParallel is the default number of steps run at once.
+const DefaultMaxParallel = 4
+
+type StepResult struct {
+	Name       string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Attempts   int
+	Err        error
+	// Skipped is set for the steps not run because an earlier step failed or the run was cancelled.
+	Skipped bool
+}
+
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %s failed: %s", e.Step, e.Err.Error())
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// ExecSteps runs the steps once the steps they need are finished, up to MaxParallel steps at once.
+// A step without needs waits for the previous step, so steps are run one by one unless they declare needs.
+// No more steps are started after a step fails, unless continueOnError is set for it, and the steps left are skipped.
+// Consecutive inline steps are run as one script. Secrets are masked in the returned errors.
+// The results are in the order of the steps. If several steps fail, the errors of all of them are returned.
+func (e *Executor) ExecSteps(steps []config.Step) ([]StepResult, error) {
+	steps = groupInlineSteps(steps)
+	deps, err := config.StepDeps(steps)
+	if err != nil {
+		return nil, err
+	}
+	e.logEnv()
+
+	maxParallel := e.MaxParallel
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+
+	type finished struct {
+		index int
+		res   StepResult
+	}
+	finishedCh := make(chan finished)
+
+	const (
+		pending = iota
+		running
+		done
+	)
+	state := make([]int, len(steps))
+	results := make([]StepResult, len(steps))
+	var stepErrs []error
+	stopped, active := false, 0
+
+	ready := func(i int) bool {
+		for _, j := range deps[i] {
+			if state[j] != done {
+				return false
+			}
+		}
+		return true
+	}
+
+	for {
+		for i, step := range steps {
+			if stopped || e.Ctx.Err() != nil || active >= maxParallel {
+				break
+			}
+			if state[i] != pending || !ready(i) {
+				continue
+			}
+			state[i], active = running, active+1
+			go func(i int, step config.Step) {
+				finishedCh <- finished{index: i, res: e.execStep(i, stepName(i, step), step)}
+			}(i, step)
+		}
+		if active == 0 {
+			break
+		}
+
+		f := <-finishedCh
+		state[f.index], active = done, active-1
+		res := f.res
+		res.Err = e.Masker.MaskError(res.Err)
+		results[f.index] = res
+		if res.Err == nil {
+			continue
+		}
+		if steps[f.index].ContinueOnError && e.Ctx.Err() == nil {
+			log.Printf("WARNING step %s failed, continuing: %s", res.Name, res.Err.Error())
+			continue
+		}
+		log.Printf("Step %s failed: %s", res.Name, res.Err.Error())
+		stepErrs = append(stepErrs, e.Masker.MaskError(&StepError{Step: res.Name, Err: res.Err}))
+		stopped = true
+	}
+
+	skipped := false
+	for i, step := range steps {
+		if state[i] == pending {
+			results[i] = StepResult{Name: stepName(i, step), Skipped: true}
+			log.Printf("Step %s skipped", results[i].Name)
+			skipped = true
+		}
+	}
+	// Steps skipped due to cancellation fail the run even if no started step failed.
+	if skipped && e.Ctx.Err() != nil {
+		err := context.Cause(e.Ctx)
+		if !errors.Is(err, context.Canceled) {
+			err = fmt.Errorf("%w: %w", context.Canceled, err)
+		}
+		stepErrs = append(stepErrs, err)
+	}
+	return results, errors.Join(stepErrs...)
+}
+
+func (e *Executor) execStep(index int, name string, step config.Step) StepResult {
+	res := StepResult{Name: name, StartedAt: time.Now()}
+
+	opts, err := e.stepOpts(step)
+	if err != nil {
+		res.Err = err
+		res.FinishedAt = time.Now()
+		return res
+	}
+	opts.prefix = name
+	if closeLog := e.logStep(index, name, opts); closeLog != nil {
+		defer closeLog()
+	}
+
+	backoff := step.Backoff
+	for attempt := 1; ; attempt++ {
+		res.Attempts = attempt
+		if attempt == 1 {
+			log.Printf("Running step %s\n", name)
+		} else {
+			log.Printf("Running step %s, attempt %d of %d\n", name, attempt, step.Retries+1)
+		}
+
+		res.Err = e.execAttempt(opts, step.Timeout)
+		if res.Err == nil || attempt > step.Retries || e.Ctx.Err() != nil {
+			break
+		}
+
+		log.Printf("Step %s failed: %s. Retrying in %s\n", name, res.Err.Error(), backoff)
+		select {
+		case <-e.Ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	res.FinishedAt = time.Now()
+	return res
+}
+
+func (e *Executor) execAttempt(opts *excuteOpts, timeout time.Duration) error {
+	ctx := e.Ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := execute(ctx, opts)
+	switch {
+	case err == nil:
+	case errors.Is(e.Ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %w", context.Canceled, err)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	return err
+}
+
+func (e *Executor) stepOpts(step config.Step) (*excuteOpts, error) {
+	args, err := e.stepArgs(step)
+	if err != nil {
+		return nil, err
+	}
+
+	envs := make([]string, 0, len(e.Env)+len(step.Env))
+	envs = append(envs, e.Env...)
+	secretEnv := make(map[string]bool)
+	for k, v := range step.Env {
+		k = strings.ToUpper(k)
+		envs = append(envs, fmt.Sprintf("%s=%s", k, v.Value))
+		if v.Secret {
+			secretEnv[k] = true
+		}
+	}
+	envs, err = e.resolveEnv(envs, secretEnv)
+	if err != nil {
+		return nil, fmt.Errorf("can't resolve envs: %w", err)
+	}
+
+	wd := e.WorkDir
+	if step.WorkDir != "" {
+		dir, err := e.resolveTemplate(step.WorkDir)
+		if err != nil {
+			return nil, fmt.Errorf("can't resolve workdir: %w", err)
+		}
+		if !filepath.IsLocal(dir) {
+			return nil, fmt.Errorf("workdir %q must be a relative path inside the repository", dir)
+		}
+		wd = filepath.Join(e.WorkDir, dir)
+	}
+
+	var stopContainer func() error
+	if e.Image != "" {
+		name := container.NewName()
+		args, err = e.Runtime.Command(container.Spec{
+			Name:     name,
+			Image:    e.Image,
+			Checkout: e.WorkDir,
+			WorkDir:  wd,
+			Env:      envNames(envs),
+		}, args)
+		if err != nil {
+			return nil, fmt.Errorf("container error: %w", err)
+		}
+		stopContainer = func() error {
+			return e.Runtime.Kill(name)
+		}
+	}
+
+	return &excuteOpts{
+		args:          args,
+		hostEnv:       e.hostEnv(),
+		env:           envs,
+		wd:            wd,
+		masker:        e.Masker,
+		gracePeriod:   e.GracePeriod,
+		sandbox:       e.Sandbox,
+		stopContainer: stopContainer,
+	}, nil
+}
+
+// stepArgs returns the templated argv of the step. Argv elements are templated one by one and run without a shell.
+// A script is run with `sh -c` and `set -e`, or passed as the last argument to the configured shell.
+func (e *Executor) stepArgs(step config.Step) ([]string, error) {
+	if len(step.Run.Argv) > 0 {
+		args, err := e.resolve(step.Run.Argv)
+		if err != nil {
+			return nil, fmt.Errorf("can't resolve command: %w", err)
+		}
+		return args, nil
+	}
+
+	script, err := e.resolveTemplate(step.Run.Script)
+	if err != nil {
+		return nil, fmt.Errorf("can't resolve command: %w", err)
+	}
+	if step.Shell.IsEmpty() {
+		return []string{"sh", "-c", "set -e\n" + script}, nil
+	}
+	return append(append([]string{}, step.Shell.Args()...), script), nil
+}
+
+// logEnv writes the resolved env of the executor to the run directory.
+func (e *Executor) logEnv() {
+	if e.Log == nil {
+		return
+	}
+	envs, err := e.resolveEnv(e.Env, nil)
+	if err == nil {
+		err = e.Log.WriteEnv(e.maskAll(envs))
+	}
+	if err != nil {
+		log.Printf("WARNING unable to write run env: %s", err.Error())
+	}
+}
+
+// logStep records the step command in the run directory and sends the step output to the step log.
+// It returns the function closing the step log.
+func (e *Executor) logStep(index int, name string, opts *excuteOpts) func() {
+	if e.Log == nil {
+		return nil
+	}
+	if err := e.Log.AddCommand(runlog.Command{
+		Step:    name,
+		Args:    e.maskAll(opts.args),
+		WorkDir: opts.wd,
+		Env:     runlog.RedactEnv(e.maskAll(opts.env[len(e.Env):])),
+	}); err != nil {
+		log.Printf("WARNING unable to write run commands: %s", err.Error())
+	}
+
+	f, err := e.Log.StepLog(index, name)
+	if err != nil {
+		log.Printf("WARNING %s", err.Error())
+		return nil
+	}
+	opts.output = f
+	return func() {
+		if err := f.Close(); err != nil {
+			log.Printf("WARNING unable to close step log: %s", err.Error())
+		}
+	}
+}
+
+// MaxParallel returns the maximum number of steps run at once: the repository setting limited by the user setting,
+// DefaultMaxParallel if neither is set.
+func MaxParallel(user, repo int) int {
+	switch {
+	case repo == 0 && user == 0:
+		return DefaultMaxParallel
+	case repo == 0:
+		return user
+	case user == 0:
+		return min(repo, DefaultMaxParallel)
+	default:
+		return min(repo, user)
+	}
+}
+
+func envNames(envs []string) []string {
+	names := make([]string, 0, len(envs))
+	seen := make(map[string]bool, len(envs))
+	for _, env := range envs {
+		name, _, _ := strings.Cut(env, "=")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (e *Executor) maskAll(values []string) []string {
+	masked := make([]string, len(values))
+	for i, v := range values {
+		masked[i] = e.Masker.Mask(v)
+	}
+	return masked
+}
+
+func groupInlineSteps(steps []config.Step) []config.Step {
+	var grouped []config.Step
+	var inline []string
+	flush := func() {
+		if len(inline) > 0 {
+			grouped = append(grouped, config.NewInlineStep(strings.Join(inline, "\n")))
+			inline = nil
+		}
+	}
+
+	for _, step := range steps {
+		if step.Inline() {
+			inline = append(inline, step.Run.Script)
+			continue
+		}
+		flush()
+		grouped = append(grouped, step)
+	}
+	flush()
+	return grouped
+}
+
+func stepName(i int, step config.Step) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
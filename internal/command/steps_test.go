@@ -0,0 +1,311 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trx/internal/config"
+	"trx/internal/container"
+	"trx/internal/runlog"
+)
+
+func newTestExecutor(t *testing.T) *Executor {
+	return &Executor{
+		Ctx:     context.Background(),
+		WorkDir: t.TempDir(),
+		Vars:    map[string]any{"RepoTag": "v1.0.0"},
+		Masker:  NewMasker(),
+	}
+}
+
+func TestExecSteps_InlineStepsShareShell(t *testing.T) {
+	e := newTestExecutor(t)
+
+	results, err := e.ExecSteps([]config.Step{
+		config.NewInlineStep("VALUE={{ .RepoTag }}"),
+		config.NewInlineStep(`echo "$VALUE" > out`),
+		{Name: "check", Run: config.Command{Script: `test "$(cat out)" = v1.0.0`}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "#1", results[0].Name)
+	assert.Equal(t, "check", results[1].Name)
+}
+
+func TestExecSteps_Retries(t *testing.T) {
+	e := newTestExecutor(t)
+
+	results, err := e.ExecSteps([]config.Step{
+		{Name: "flaky", Run: config.Command{Script: "echo >> attempts; test $(cat attempts | wc -l) -ge 3"}, Retries: 3, Backoff: time.Millisecond},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, results[0].Attempts)
+
+	results, err = e.ExecSteps([]config.Step{
+		{Name: "broken", Run: config.Command{Script: "exit 3"}, Retries: 1},
+	})
+	var stepErr *StepError
+	require.ErrorAs(t, err, &stepErr)
+	assert.Equal(t, "broken", stepErr.Step)
+	assert.Equal(t, 2, results[0].Attempts)
+}
+
+func TestExecSteps_ContinueOnError(t *testing.T) {
+	e := newTestExecutor(t)
+
+	results, err := e.ExecSteps([]config.Step{
+		{Name: "optional", Run: config.Command{Script: "exit 1"}, ContinueOnError: true},
+		{Name: "required", Run: config.Command{Script: "touch done"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Error(t, results[0].Err)
+	assert.FileExists(t, filepath.Join(e.WorkDir, "done"))
+}
+
+func TestExecSteps_WorkDirAndEnv(t *testing.T) {
+	e := newTestExecutor(t)
+	require.NoError(t, os.Mkdir(filepath.Join(e.WorkDir, "deploy"), 0o755))
+
+	_, err := e.ExecSteps([]config.Step{
+		{Run: config.Command{Script: `echo "$TARGET" > out`}, WorkDir: "deploy", Env: map[string]config.EnvVar{"target": {Value: "{{ .RepoTag }}"}}},
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(e.WorkDir, "deploy", "out"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0\n", string(data))
+
+	_, err = e.ExecSteps([]config.Step{{Run: config.Command{Script: "true"}, WorkDir: "../outside"}})
+	assert.ErrorContains(t, err, "must be a relative path inside the repository")
+}
+
+func TestExecSteps_Timeout(t *testing.T) {
+	e := newTestExecutor(t)
+
+	_, err := e.ExecSteps([]config.Step{{Name: "slow", Run: config.Command{Script: "exec sleep 5"}, Timeout: 100 * time.Millisecond}})
+	assert.ErrorContains(t, err, "step slow failed: timed out after 100ms")
+}
+
+func TestExecSteps_Argv(t *testing.T) {
+	e := newTestExecutor(t)
+	e.Vars["RepoTag"] = "v1.0.0; touch injected"
+
+	_, err := e.ExecSteps([]config.Step{
+		{Run: config.Command{Argv: []string{"sh", "-c", `printf '%s' "$1" > out`, "sh", "{{ .RepoTag }}"}}},
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(e.WorkDir, "out"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0; touch injected", string(data))
+	assert.NoFileExists(t, filepath.Join(e.WorkDir, "injected"))
+}
+
+func TestExecSteps_Shell(t *testing.T) {
+	e := newTestExecutor(t)
+
+	_, err := e.ExecSteps([]config.Step{
+		{Run: config.Command{Script: `echo "$0" > out`}, Shell: config.Command{Script: "sh -c"}},
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(e.WorkDir, "out"))
+	require.NoError(t, err)
+	assert.Equal(t, "sh\n", string(data))
+}
+
+func TestExecSteps_RunLog(t *testing.T) {
+	e := newTestExecutor(t)
+	e.Env = []string{"API_TOKEN=secret"}
+	run, err := runlog.Create(t.TempDir(), "run")
+	require.NoError(t, err)
+	defer run.Close()
+	e.Log = run
+
+	_, err = e.ExecSteps([]config.Step{
+		{Name: "greet", Run: config.Command{Argv: []string{"echo", "hello"}}, Env: map[string]config.EnvVar{"db_password": {Value: "p"}}},
+	})
+	require.NoError(t, err)
+
+	stepLog, err := os.ReadFile(filepath.Join(run.Dir, runlog.DirSteps, "01-greet.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "[stdout] hello\n", string(stepLog))
+
+	commands, err := os.ReadFile(filepath.Join(run.Dir, runlog.FileCommands))
+	require.NoError(t, err)
+	assert.Contains(t, string(commands), `"DB_PASSWORD=***"`)
+
+	env, err := os.ReadFile(filepath.Join(run.Dir, runlog.FileEnv))
+	require.NoError(t, err)
+	assert.Equal(t, "API_TOKEN=***\n", string(env))
+}
+
+func TestExecSteps_EnvPolicy(t *testing.T) {
+	t.Setenv("TRX_TEST_HOST_VALUE", "host")
+	e := newTestExecutor(t)
+
+	_, err := e.ExecSteps([]config.Step{
+		config.NewInlineStep(`test -z "$TRX_TEST_HOST_VALUE" && test -n "$PATH"`),
+	})
+	require.NoError(t, err)
+
+	e.EnvPolicy = &config.EnvPolicy{Allowlist: []string{"TRX_TEST_HOST_VALUE"}}
+	_, err = e.ExecSteps([]config.Step{
+		config.NewInlineStep(`test "$TRX_TEST_HOST_VALUE" = host`),
+	})
+	require.NoError(t, err)
+}
+
+func TestExecSteps_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := newTestExecutor(t)
+	e.Ctx = ctx
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	results, err := e.ExecSteps([]config.Step{
+		{Name: "long", Run: config.Command{Script: "sleep 10"}, Retries: 3},
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, results[0].Attempts)
+}
+
+func TestExecSteps_CancelledBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("lock lost"))
+	e := newTestExecutor(t)
+	e.Ctx = ctx
+
+	results, err := e.ExecSteps([]config.Step{
+		{Name: "first", Run: config.Command{Script: "true"}},
+		{Name: "second", Run: config.Command{Script: "true"}},
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.ErrorContains(t, err, "lock lost")
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Skipped)
+	assert.True(t, results[1].Skipped)
+}
+
+// fakeRuntime runs the commands on the host and records the container specs and killed containers.
+type fakeRuntime struct {
+	specs  []container.Spec
+	mu     sync.Mutex
+	killed []string
+}
+
+func (r *fakeRuntime) Command(spec container.Spec, args []string) ([]string, error) {
+	r.specs = append(r.specs, spec)
+	return args, nil
+}
+
+func (r *fakeRuntime) Kill(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.killed = append(r.killed, name)
+	return nil
+}
+
+func (r *fakeRuntime) killedNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.killed...)
+}
+
+func TestExecSteps_Image(t *testing.T) {
+	e := newTestExecutor(t)
+	e.Env = []string{"WERF_ENV=production"}
+	runtime := &fakeRuntime{}
+	e.Image, e.Runtime = "alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", runtime
+	require.NoError(t, os.Mkdir(filepath.Join(e.WorkDir, "deploy"), 0o755))
+
+	_, err := e.ExecSteps([]config.Step{
+		{Run: config.Command{Script: `test "$WERF_ENV" = production`}, WorkDir: "deploy", Env: map[string]config.EnvVar{"token": {Value: "t"}}},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, runtime.specs, 1)
+	assert.NotEmpty(t, runtime.specs[0].Name)
+	assert.Equal(t, container.Spec{
+		Name:     runtime.specs[0].Name,
+		Image:    e.Image,
+		Checkout: e.WorkDir,
+		WorkDir:  filepath.Join(e.WorkDir, "deploy"),
+		Env:      []string{"WERF_ENV", "TOKEN"},
+	}, runtime.specs[0])
+	assert.Empty(t, runtime.killedNames())
+}
+
+func TestExecSteps_ImageCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := newTestExecutor(t)
+	e.Ctx, e.GracePeriod = ctx, 100*time.Millisecond
+	runtime := &fakeRuntime{}
+	e.Image, e.Runtime = "alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", runtime
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	// The command ignores SIGTERM like a runtime client forwarding it to the container.
+	_, err := e.ExecSteps([]config.Step{
+		{Name: "long", Run: config.Command{Script: "trap '' TERM; sleep 10"}},
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.Len(t, runtime.specs, 1)
+	assert.Equal(t, []string{runtime.specs[0].Name}, runtime.killedNames())
+}
+
+func TestExecSteps_Needs(t *testing.T) {
+	buf := captureLog(t)
+	e := newTestExecutor(t)
+	e.MaxParallel = 2
+
+	start := time.Now()
+	results, err := e.ExecSteps([]config.Step{
+		{Name: "docs", Run: config.Command{Script: "sleep 0.3; echo docs > docs"}, Needs: []string{}},
+		{Name: "migrate", Run: config.Command{Script: "sleep 0.3; echo migrate > migrate"}, Needs: []string{}},
+		{Name: "deploy", Run: config.Command{Script: "cat docs migrate"}, Needs: []string{"docs", "migrate"}},
+	})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 550*time.Millisecond, "independent steps must run concurrently")
+
+	require.Len(t, results, 3)
+	assert.Equal(t, "deploy", results[2].Name)
+	assert.Contains(t, buf.String(), "[deploy] [stdout] docs\n")
+	assert.Contains(t, buf.String(), "[deploy] [stdout] migrate\n")
+}
+
+func TestExecSteps_NeedsFailure(t *testing.T) {
+	captureLog(t)
+	e := newTestExecutor(t)
+	e.MaxParallel = 2
+
+	results, err := e.ExecSteps([]config.Step{
+		{Name: "migrate-a", Run: config.Command{Script: "exit 2"}, Needs: []string{}},
+		{Name: "migrate-b", Run: config.Command{Script: "sleep 0.2; touch b"}, Needs: []string{}},
+		{Name: "deploy", Run: config.Command{Script: "touch deployed"}, Needs: []string{"migrate-a", "migrate-b"}},
+	})
+	var stepErr *StepError
+	require.ErrorAs(t, err, &stepErr)
+	assert.Equal(t, "migrate-a", stepErr.Step)
+
+	require.Len(t, results, 3)
+	assert.Error(t, results[0].Err)
+	assert.NoError(t, results[1].Err, "running steps finish after a failure")
+	assert.FileExists(t, filepath.Join(e.WorkDir, "b"))
+	assert.True(t, results[2].Skipped)
+	assert.NoFileExists(t, filepath.Join(e.WorkDir, "deployed"))
+}
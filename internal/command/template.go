@@ -0,0 +1,92 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const shortShaLength = 7
+
+// newTemplateFuncs returns the functions available in command, env and hook templates.
+// The `env` function reads the variables of the trx process with the given lookup function.
+func newTemplateFuncs(lookupEnv func(string) string) template.FuncMap {
+	return template.FuncMap{
+		"semverMajor": func(v string) (uint64, error) {
+			sv, err := semver.NewVersion(v)
+			if err != nil {
+				return 0, err
+			}
+			return sv.Major(), nil
+		},
+		"semverMinor": func(v string) (uint64, error) {
+			sv, err := semver.NewVersion(v)
+			if err != nil {
+				return 0, err
+			}
+			return sv.Minor(), nil
+		},
+		"semverPatch": func(v string) (uint64, error) {
+			sv, err := semver.NewVersion(v)
+			if err != nil {
+				return 0, err
+			}
+			return sv.Patch(), nil
+		},
+		"semverPrerelease": func(v string) (string, error) {
+			sv, err := semver.NewVersion(v)
+			if err != nil {
+				return "", err
+			}
+			return sv.Prerelease(), nil
+		},
+		"default": func(def string, v string) string {
+			if v == "" {
+				return def
+			}
+			return v
+		},
+		"env":        lookupEnv,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"shortSha":   ShortSha,
+		"join":       func(sep string, elems []string) string { return strings.Join(elems, sep) },
+		"quote":      strconv.Quote,
+		"shellQuote": shellQuote,
+		"toJson": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}
+}
+
+// ShortSha returns the abbreviated commit hash.
+func ShortSha(sha string) string {
+	if len(sha) > shortShaLength {
+		return sha[:shortShaLength]
+	}
+	return sha
+}
+
+// shellQuote quotes the string for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func resolveTemplate(tmpl string, vars map[string]any, funcs template.FuncMap) (string, error) {
+	t, err := template.New("cmd").Funcs(funcs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,71 @@
+package command
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trx/internal/config"
+)
+
+func TestResolveTemplate(t *testing.T) {
+	vars := map[string]any{
+		"RepoTag":    "v1.4.2-rc.1",
+		"RepoCommit": "0123456789abcdef",
+		"RepoUrl":    "https://example.com/a&b<c>.git",
+		"Empty":      "",
+		"Signers":    map[string][]string{"main": {"AAAA", "BBBB"}},
+	}
+
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{`{{ .RepoUrl }}`, `https://example.com/a&b<c>.git`},
+		{`{{ .RepoTag | semverMajor }}.{{ .RepoTag | semverMinor }}.{{ .RepoTag | semverPatch }}`, `1.4.2`},
+		{`{{ .RepoTag | semverPrerelease }}`, `rc.1`},
+		{`{{ .RepoTag | trimPrefix "v" }}`, `1.4.2-rc.1`},
+		{`{{ .RepoTag | trimSuffix "-rc.1" }}`, `v1.4.2`},
+		{`{{ .RepoCommit | shortSha }}`, `0123456`},
+		{`{{ .Empty | default "none" }}`, `none`},
+		{`{{ .RepoTag | default "none" }}`, `v1.4.2-rc.1`},
+		{`{{ "it's" | shellQuote }}`, `'it'\''s'`},
+		{`{{ .RepoTag | quote }}`, `"v1.4.2-rc.1"`},
+		{`{{ .RepoTag | toJson }}`, `"v1.4.2-rc.1"`},
+		{`{{ index .Signers "main" | join ", " }}`, `AAAA, BBBB`},
+		{`{{ .Signers | toJson }}`, `{"main":["AAAA","BBBB"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			res, err := resolveTemplate(tt.tmpl, vars, newTemplateFuncs(os.Getenv))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, res)
+		})
+	}
+}
+
+func TestResolveTemplate_Errors(t *testing.T) {
+	vars := map[string]any{"RepoTag": "latest"}
+
+	_, err := resolveTemplate(`{{ .Unknown }}`, vars, newTemplateFuncs(os.Getenv))
+	assert.ErrorContains(t, err, `map has no entry for key "Unknown"`)
+
+	_, err = resolveTemplate(`{{ .RepoTag | semverMajor }}`, vars, newTemplateFuncs(os.Getenv))
+	assert.Error(t, err)
+}
+
+func TestResolveTemplate_Env(t *testing.T) {
+	t.Setenv("TRX_TEST_VALUE", "value")
+
+	e := &Executor{EnvPolicy: &config.EnvPolicy{Allowlist: []string{"TRX_TEST_VALUE"}}}
+	res, err := e.resolveTemplate(`{{ env "TRX_TEST_VALUE" }}`)
+	require.NoError(t, err)
+	assert.Equal(t, "value", res)
+
+	e.EnvPolicy = nil
+	res, err = e.resolveTemplate(`{{ env "TRX_TEST_VALUE" }}`)
+	require.NoError(t, err)
+	assert.Empty(t, res)
+}
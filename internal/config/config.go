@@ -4,20 +4,35 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/go-playground/validator/v10"
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 type Config struct {
-	Repo    GitRepo           `mapstructure:"repo" validate:"required"`
-	Quorums []Quorum          `mapstructure:"quorums" validate:"required,min=1"`
-	Env     map[string]string `mapstructure:"env"`
+	Repo      GitRepo           `mapstructure:"repo" validate:"required"`
+	Quorums   []Quorum          `mapstructure:"quorums" validate:"required,min=1,dive"`
+	Env       map[string]EnvVar `mapstructure:"env"`
+	EnvPolicy *EnvPolicy        `mapstructure:"envPolicy,omitempty"`
 
-	Hooks             *Hooks   `mapstructure:"hooks,omitempty"`
-	InitLastPublished string   `mapstructure:"initial_last_published_git_commit"`
-	Commands          []string `mapstructure:"commands"`
+	RollbackQuorums []Quorum `mapstructure:"rollbackQuorums" validate:"dive"`
+	Storage         *Storage `mapstructure:"storage,omitempty"`
+	Lock            *Lock    `mapstructure:"lock,omitempty"`
+	Runs            *Runs    `mapstructure:"runs,omitempty"`
+	Sandbox         *Sandbox `mapstructure:"sandbox,omitempty"`
+
+	ContainerRuntime string `mapstructure:"containerRuntime" validate:"omitempty,oneof=docker podman"`
+	MaxParallel      int    `mapstructure:"maxParallel" validate:"gte=0"`
+
+	Hooks             *Hooks `mapstructure:"hooks,omitempty"`
+	InitLastPublished string `mapstructure:"initial_last_published_git_commit"`
+	Commands          []Step `mapstructure:"commands" validate:"dive"`
+
+	TerminationGracePeriod time.Duration `mapstructure:"terminationGracePeriod" validate:"gte=0"`
 }
 
 type GitRepo struct {
@@ -43,14 +58,106 @@ type Quorum struct {
 	MinNumberOfKeys  int      `mapstructure:"minNumberOfKeys" validate:"required,gt=0"`
 	GPGKeys          []string `mapstructure:"gpgKeys"`
 	GPGKeyFilesPaths []string `mapstructure:"gpgKeyPaths"`
+
+	// Versions and Bump select the tags the quorum is required for. A quorum without them is required for every tag.
+	Versions string `mapstructure:"versions"`
+	Bump     string `mapstructure:"bump" validate:"omitempty,oneof=major minor patch"`
+}
+
+type Storage struct {
+	Type       string             `mapstructure:"type" validate:"omitempty,oneof=local s3 kubernetes"`
+	Local      *LocalStorage      `mapstructure:"local,omitempty"`
+	S3         *S3                `mapstructure:"s3,omitempty" validate:"required_if=Type s3"`
+	Kubernetes *KubernetesStorage `mapstructure:"kubernetes,omitempty"`
+}
+
+type LocalStorage struct {
+	Path string `mapstructure:"path"`
+}
+
+type S3 struct {
+	Bucket          string `mapstructure:"bucket" validate:"required"`
+	Prefix          string `mapstructure:"prefix"`
+	Endpoint        string `mapstructure:"endpoint"`
+	Region          string `mapstructure:"region"`
+	AccessKeyID     string `mapstructure:"accessKeyId"`
+	SecretAccessKey string `mapstructure:"secretAccessKey"`
+	SessionToken    string `mapstructure:"sessionToken"`
+	Insecure        bool   `mapstructure:"insecure"`
+	PathStyle       bool   `mapstructure:"pathStyle"`
+}
+
+type KubernetesStorage struct {
+	Kubernetes `mapstructure:",squash"`
+	ConfigMap  string `mapstructure:"configMap" validate:"omitempty,max=63"`
+}
+
+type Lock struct {
+	Type       string          `mapstructure:"type" validate:"omitempty,oneof=local kubernetes s3"`
+	Timeout    time.Duration   `mapstructure:"timeout" validate:"gte=0"`
+	OnBusy     string          `mapstructure:"onBusy" validate:"omitempty,oneof=wait skip fail"`
+	LeaseTTL   time.Duration   `mapstructure:"leaseTTL" validate:"omitempty,gte=6s"`
+	Kubernetes *KubernetesLock `mapstructure:"kubernetes,omitempty"`
+	S3         *S3             `mapstructure:"s3,omitempty" validate:"required_if=Type s3"`
+}
+
+type KubernetesLock struct {
+	Kubernetes `mapstructure:",squash"`
+	Lease      string `mapstructure:"lease"`
+}
+
+type Kubernetes struct {
+	Kubeconfig string `mapstructure:"kubeconfig"`
+	Context    string `mapstructure:"context"`
+	Namespace  string `mapstructure:"namespace"`
+}
+
+type Runs struct {
+	Path     string        `mapstructure:"path"`
+	KeepLast int           `mapstructure:"keepLast" validate:"gte=0"`
+	MaxAge   time.Duration `mapstructure:"maxAge" validate:"gte=0"`
+}
+
+// Sandbox restricts the commands of the repository. It is set in the user config only, so the repository can't relax it.
+type Sandbox struct {
+	UID              *int           `mapstructure:"uid" validate:"omitempty,gte=0"`
+	GID              *int           `mapstructure:"gid" validate:"required_with=UID,omitempty,gte=0"`
+	ReadOnlyCheckout bool           `mapstructure:"readOnlyCheckout"`
+	PrivateTmp       bool           `mapstructure:"privateTmp"`
+	IsolateNetwork   bool           `mapstructure:"isolateNetwork"`
+	Limits           *SandboxLimits `mapstructure:"limits,omitempty"`
+}
+
+type SandboxLimits struct {
+	CPU       time.Duration `mapstructure:"cpu" validate:"gte=0"`
+	Memory    string        `mapstructure:"memory"`
+	OpenFiles uint64        `mapstructure:"openFiles"`
+}
+
+// MemoryBytes parses the memory limit, e.g. `512Mi` or `2G`. It returns 0 if no limit is set.
+func (l *SandboxLimits) MemoryBytes() (uint64, error) {
+	if l.Memory == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(l.Memory)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sandbox memory limit %q: %w", l.Memory, err)
+	}
+	if q.Sign() <= 0 {
+		return 0, fmt.Errorf("invalid sandbox memory limit %q: must be positive", l.Memory)
+	}
+	return uint64(q.Value()), nil
 }
 
 type Hooks struct {
-	OnCommandSuccess *[]string `mapstructure:"onCommandSuccess,omitempty"`
-	OnCommandFailure *[]string `mapstructure:"onCommandFailure,omitempty"`
-	OnCommandSkipped *[]string `mapstructure:"onCommandSkipped,omitempty"`
-	OnQuorumFailure  *[]string `mapstructure:"onQuorumFailure,omitempty"`
-	OnCommandStarted *[]string `mapstructure:"onCommandStarted,omitempty"`
+	OnCommandSuccess   *[]string `mapstructure:"onCommandSuccess,omitempty"`
+	OnCommandFailure   *[]string `mapstructure:"onCommandFailure,omitempty"`
+	OnCommandSkipped   *[]string `mapstructure:"onCommandSkipped,omitempty"`
+	OnQuorumFailure    *[]string `mapstructure:"onQuorumFailure,omitempty"`
+	OnCommandStarted   *[]string `mapstructure:"onCommandStarted,omitempty"`
+	OnRollback         *[]string `mapstructure:"onRollback,omitempty"`
+	OnTagRetargeted    *[]string `mapstructure:"onTagRetargeted,omitempty"`
+	OnCommandCancelled *[]string `mapstructure:"onCommandCancelled,omitempty"`
 }
 
 func NewConfig(configPath string) (*Config, error) {
@@ -84,6 +191,24 @@ func (config *Config) Validate() error {
 		return err
 	}
 
+	if err := validateQuorums(config.RollbackQuorums); err != nil {
+		return fmt.Errorf("rollback quorums: %w", err)
+	}
+
+	if err := validateSteps(config.Commands); err != nil {
+		return err
+	}
+
+	if err := config.EnvPolicy.validate(); err != nil {
+		return err
+	}
+
+	if config.Sandbox != nil && config.Sandbox.Limits != nil {
+		if _, err := config.Sandbox.Limits.MemoryBytes(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -126,6 +251,12 @@ func validateQuorums(quorums []Quorum) error {
 		if err := validateKeyFilePath(q.GPGKeyFilesPaths); err != nil {
 			return err
 		}
+
+		if q.Versions != "" {
+			if _, err := semver.NewConstraint(q.Versions); err != nil {
+				return fmt.Errorf("invalid quorum versions constraint %q: %w", q.Versions, err)
+			}
+		}
 	}
 	return nil
 }
@@ -162,6 +293,11 @@ func loadConfig(configPath string, defaultFunc func(), config interface{}, valid
 	}
 
 	decoderConfig := &mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			stepHookFunc(),
+			envVarHookFunc(),
+		),
 		ErrorUnused: true,
 		Result:      config,
 	}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	EnvPolicyInherit   = "inherit"
+	EnvPolicyMinimal   = "minimal"
+	EnvPolicyAllowlist = "allowlist"
+)
+
+// MinimalEnv are the variables of the trx process passed to the commands with the `minimal` and `allowlist` policies.
+var MinimalEnv = []string{"PATH", "HOME", "LANG"}
+
+// EnvVar is an env variable specified either as a plain string or as a map with the value and the secret flag.
+// Values of secret variables are masked in the command output.
+type EnvVar struct {
+	Value  string `mapstructure:"value"`
+	Secret bool   `mapstructure:"secret"`
+}
+
+// EnvPolicy selects the variables of the trx process passed to the commands.
+// It is specified either as a mode name or as a map with the allowlist.
+type EnvPolicy struct {
+	Mode      string   `mapstructure:"mode" validate:"omitempty,oneof=inherit minimal allowlist"`
+	Allowlist []string `mapstructure:"allowlist"`
+}
+
+func envVarHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		switch {
+		case t == reflect.TypeOf(EnvVar{}) && f.Kind() == reflect.String:
+			return EnvVar{Value: data.(string)}, nil
+		case t == reflect.TypeOf(EnvPolicy{}) && f.Kind() == reflect.String:
+			return EnvPolicy{Mode: data.(string)}, nil
+		}
+		return data, nil
+	}
+}
+
+// mode returns the policy mode, `minimal` by default.
+func (p *EnvPolicy) mode() string {
+	switch {
+	case p == nil:
+		return EnvPolicyMinimal
+	case p.Mode != "":
+		return p.Mode
+	case len(p.Allowlist) > 0:
+		return EnvPolicyAllowlist
+	default:
+		return EnvPolicyMinimal
+	}
+}
+
+func (p *EnvPolicy) String() string {
+	if p.mode() == EnvPolicyAllowlist {
+		return fmt.Sprintf("%s %v", EnvPolicyAllowlist, p.Allowlist)
+	}
+	return p.mode()
+}
+
+// Allows reports whether the variable of the trx process is passed to the commands.
+func (p *EnvPolicy) Allows(name string) bool {
+	switch p.mode() {
+	case EnvPolicyInherit:
+		return true
+	case EnvPolicyAllowlist:
+		if containsEnvName(p.Allowlist, name) {
+			return true
+		}
+	}
+	return containsEnvName(MinimalEnv, name)
+}
+
+// Filter returns the variables of the environ allowed by the policy.
+func (p *EnvPolicy) Filter(environ []string) []string {
+	var filtered []string
+	for _, env := range environ {
+		name, _, _ := strings.Cut(env, "=")
+		if p.Allows(name) {
+			filtered = append(filtered, env)
+		}
+	}
+	return filtered
+}
+
+// Restrict returns the policy allowing only the variables allowed by both policies,
+// so the policy of the repository config can narrow the user policy, but not widen it.
+func (p *EnvPolicy) Restrict(other *EnvPolicy) *EnvPolicy {
+	switch {
+	case other == nil:
+		return p
+	case p.mode() == EnvPolicyInherit:
+		return other
+	case other.mode() == EnvPolicyInherit:
+		return p
+	}
+
+	var allowlist []string
+	if p.mode() == EnvPolicyAllowlist && other.mode() == EnvPolicyAllowlist {
+		for _, name := range p.Allowlist {
+			if containsEnvName(other.Allowlist, name) {
+				allowlist = append(allowlist, name)
+			}
+		}
+	}
+	if len(allowlist) == 0 {
+		return &EnvPolicy{Mode: EnvPolicyMinimal}
+	}
+	return &EnvPolicy{Mode: EnvPolicyAllowlist, Allowlist: allowlist}
+}
+
+func (p *EnvPolicy) validate() error {
+	if p != nil && len(p.Allowlist) > 0 && p.mode() != EnvPolicyAllowlist {
+		return fmt.Errorf("env policy allowlist can't be used with the %s mode", p.Mode)
+	}
+	return nil
+}
+
+func containsEnvName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Secrets returns the credentials from the config that must not appear in the command output.
+// Values of secret env variables are templates and are collected by the executor after they are resolved.
+func (config *Config) Secrets() []string {
+	var secrets []string
+	auth := config.Repo.Auth
+	secrets = append(secrets, auth.SshKeyPassword)
+	if auth.BasicAuth != nil {
+		secrets = append(secrets, auth.BasicAuth.Password)
+	}
+	if config.Storage != nil && config.Storage.S3 != nil {
+		secrets = append(secrets, config.Storage.S3.SecretAccessKey, config.Storage.S3.SessionToken)
+	}
+	if config.Lock != nil && config.Lock.S3 != nil {
+		secrets = append(secrets, config.Lock.S3.SecretAccessKey, config.Lock.S3.SessionToken)
+	}
+	return secrets
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvPolicy_Filter(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/root", "LANG=C", "AWS_SECRET_ACCESS_KEY=x", "SSH_AUTH_SOCK=/tmp/agent"}
+
+	var def *EnvPolicy
+	assert.Equal(t, []string{"PATH=/bin", "HOME=/root", "LANG=C"}, def.Filter(environ))
+	assert.Equal(t, environ, (&EnvPolicy{Mode: EnvPolicyInherit}).Filter(environ))
+	assert.Equal(t, []string{"PATH=/bin", "HOME=/root", "LANG=C", "SSH_AUTH_SOCK=/tmp/agent"},
+		(&EnvPolicy{Allowlist: []string{"SSH_AUTH_SOCK"}}).Filter(environ))
+}
+
+func TestEnvPolicy_Restrict(t *testing.T) {
+	inherit := &EnvPolicy{Mode: EnvPolicyInherit}
+	minimal := &EnvPolicy{Mode: EnvPolicyMinimal}
+	allowlist := &EnvPolicy{Allowlist: []string{"A", "B"}}
+
+	tests := []struct {
+		name       string
+		user, repo *EnvPolicy
+		want       string
+	}{
+		{"repo without policy", allowlist, nil, "allowlist [A B]"},
+		{"default user policy", nil, inherit, "minimal"},
+		{"repo can't widen minimal", minimal, allowlist, "minimal"},
+		{"repo can narrow inherit", inherit, allowlist, "allowlist [A B]"},
+		{"allowlists intersect", allowlist, &EnvPolicy{Allowlist: []string{"B", "C"}}, "allowlist [B]"},
+		{"disjoint allowlists", allowlist, &EnvPolicy{Allowlist: []string{"C"}}, "minimal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.user.Restrict(tt.repo).String())
+		})
+	}
+}
+
+func TestNewRunnerConfig_EnvPolicy(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(wd, "trx.yaml"), []byte(`
+commands:
+  - make
+envPolicy:
+  allowlist: [SSH_AUTH_SOCK]
+`), 0o644))
+
+	cfg, err := NewRunnerConfig(wd, "")
+	require.NoError(t, err)
+	assert.Equal(t, &EnvPolicy{Allowlist: []string{"SSH_AUTH_SOCK"}}, cfg.EnvPolicy)
+
+	require.NoError(t, os.WriteFile(filepath.Join(wd, "trx.yaml"), []byte(`
+commands:
+  - make
+envPolicy: everything
+`), 0o644))
+	_, err = NewRunnerConfig(wd, "")
+	assert.Error(t, err)
+}
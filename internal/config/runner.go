@@ -6,11 +6,17 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
+
+	"trx/internal/container"
 )
 
 type RunnerConfig struct {
-	Commands []string          `mapstructure:"commands"`
-	Env      map[string]string `mapstructure:"env"`
+	Commands  []Step            `mapstructure:"commands" validate:"dive"`
+	Env       map[string]EnvVar `mapstructure:"env"`
+	EnvPolicy *EnvPolicy        `mapstructure:"envPolicy,omitempty"`
+	Image     string            `mapstructure:"image"`
+	// MaxParallel is limited by the user config.
+	MaxParallel int `mapstructure:"maxParallel" validate:"gte=0"`
 }
 
 func NewRunnerConfig(wd, configPath string) (*RunnerConfig, error) {
@@ -44,5 +50,19 @@ func (config *RunnerConfig) Validate() error {
 		return fmt.Errorf("runner config error: no commands to run")
 	}
 
+	if err := validateSteps(config.Commands); err != nil {
+		return fmt.Errorf("runner config error: %w", err)
+	}
+
+	if err := config.EnvPolicy.validate(); err != nil {
+		return fmt.Errorf("runner config error: %w", err)
+	}
+
+	if config.Image != "" {
+		if err := container.ValidateImage(config.Image); err != nil {
+			return fmt.Errorf("runner config error: %w", err)
+		}
+	}
+
 	return nil
 }
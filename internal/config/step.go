@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Step is a command to run. It is specified either as a plain string or as a map with the step options.
+type Step struct {
+	Name            string            `mapstructure:"name"`
+	Run             Command           `mapstructure:"run"`
+	Shell           Command           `mapstructure:"shell"`
+	Timeout         time.Duration     `mapstructure:"timeout" validate:"gte=0"`
+	Retries         int               `mapstructure:"retries" validate:"gte=0"`
+	Backoff         time.Duration     `mapstructure:"backoff" validate:"gte=0"`
+	WorkDir         string            `mapstructure:"workdir"`
+	Env             map[string]EnvVar `mapstructure:"env"`
+	ContinueOnError bool              `mapstructure:"continueOnError"`
+	// Needs are the names of the steps this step waits for. A step without needs waits for the previous step.
+	Needs []string `mapstructure:"needs"`
+
+	inline bool
+}
+
+// Command is either a script run with a shell, or an argv list run directly without a shell.
+type Command struct {
+	Script string
+	Argv   []string
+}
+
+// NewInlineStep creates a step from a plain string command.
+func NewInlineStep(script string) Step {
+	return Step{Run: Command{Script: script}, inline: true}
+}
+
+// Inline reports whether the step is specified as a plain string.
+// Consecutive plain string steps are run as one script to keep the shell state between them.
+func (s Step) Inline() bool {
+	return s.inline
+}
+
+func (c Command) IsEmpty() bool {
+	return c.Script == "" && len(c.Argv) == 0
+}
+
+// Args returns the argv list, splitting the script by whitespace if the command is specified as a string.
+func (c Command) Args() []string {
+	if len(c.Argv) > 0 {
+		return c.Argv
+	}
+	return strings.Fields(c.Script)
+}
+
+func (c Command) String() string {
+	if len(c.Argv) > 0 {
+		return strings.Join(c.Argv, " ")
+	}
+	return c.Script
+}
+
+func stepHookFunc() mapstructure.DecodeHookFuncType {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		switch {
+		case t == reflect.TypeOf(Step{}) && f.Kind() == reflect.String:
+			return NewInlineStep(data.(string)), nil
+		case t == reflect.TypeOf(Command{}) && f.Kind() == reflect.String:
+			return Command{Script: data.(string)}, nil
+		case t == reflect.TypeOf(Command{}) && f.Kind() == reflect.Slice:
+			var argv []string
+			if err := mapstructure.Decode(data, &argv); err != nil {
+				return nil, err
+			}
+			return Command{Argv: argv}, nil
+		}
+		return data, nil
+	}
+}
+
+func validateSteps(steps []Step) error {
+	for i, s := range steps {
+		switch {
+		case s.Run.IsEmpty():
+			return fmt.Errorf("step %d: run is required", i+1)
+		case len(s.Run.Argv) > 0 && !s.Shell.IsEmpty():
+			return fmt.Errorf("step %d: shell can't be used with run specified as a list", i+1)
+		case s.WorkDir != "" && !filepath.IsLocal(s.WorkDir):
+			return fmt.Errorf("step %d: workdir %q must be a relative path inside the repository", i+1, s.WorkDir)
+		}
+	}
+	_, err := StepDeps(steps)
+	return err
+}
+
+// StepDeps returns the indexes of the steps each step depends on.
+// A step without needs depends on the previous step, so steps are run one by one unless they declare needs.
+func StepDeps(steps []Step) ([][]int, error) {
+	index := make(map[string]int, len(steps))
+	for i, s := range steps {
+		if s.Name == "" {
+			continue
+		}
+		if _, ok := index[s.Name]; ok {
+			return nil, fmt.Errorf("step %d: duplicate step name %q", i+1, s.Name)
+		}
+		index[s.Name] = i
+	}
+
+	deps := make([][]int, len(steps))
+	for i, s := range steps {
+		if s.Needs == nil {
+			if i > 0 {
+				deps[i] = []int{i - 1}
+			}
+			continue
+		}
+		deps[i] = []int{}
+		for _, name := range s.Needs {
+			j, ok := index[name]
+			switch {
+			case !ok:
+				return nil, fmt.Errorf("step %d: unknown step %q in needs", i+1, name)
+			case j == i:
+				return nil, fmt.Errorf("step %d: step can't need itself", i+1)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	if err := checkStepCycles(steps, deps); err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
+
+func checkStepCycles(steps []Step, deps [][]int) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(steps))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("step %d: needs form a cycle", i+1)
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		return nil
+	}
+	for i := range steps {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
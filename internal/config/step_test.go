@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRunnerConfig_Steps(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(wd, "trx.yaml"), []byte(`
+commands:
+  - echo "plain"
+  - name: converge
+    run: werf converge
+    timeout: 10m
+    retries: 2
+    backoff: 30s
+    workdir: deploy
+    env:
+      WERF_ENV: production
+      REGISTRY_PASSWORD:
+        value: "{{ env \"CI_REGISTRY_PASSWORD\" }}"
+        secret: true
+    continueOnError: true
+  - run: ["werf", "converge", "--env", "{{ .RepoTag }}"]
+  - run: echo "$0"
+    shell: bash -eo pipefail -c
+`), 0o644))
+
+	cfg, err := NewRunnerConfig(wd, "")
+	require.NoError(t, err)
+	require.Len(t, cfg.Commands, 4)
+
+	assert.True(t, cfg.Commands[0].Inline())
+	assert.Equal(t, Command{Script: `echo "plain"`}, cfg.Commands[0].Run)
+
+	step := cfg.Commands[1]
+	assert.False(t, step.Inline())
+	assert.Equal(t, "converge", step.Name)
+	assert.Equal(t, "werf converge", step.Run.Script)
+	assert.Equal(t, 10*time.Minute, step.Timeout)
+	assert.Equal(t, 2, step.Retries)
+	assert.Equal(t, 30*time.Second, step.Backoff)
+	assert.Equal(t, "deploy", step.WorkDir)
+	assert.Equal(t, map[string]EnvVar{
+		"werf_env":          {Value: "production"},
+		"registry_password": {Value: `{{ env "CI_REGISTRY_PASSWORD" }}`, Secret: true},
+	}, step.Env)
+	assert.True(t, step.ContinueOnError)
+
+	assert.Equal(t, Command{Argv: []string{"werf", "converge", "--env", "{{ .RepoTag }}"}}, cfg.Commands[2].Run)
+	assert.Equal(t, []string{"bash", "-eo", "pipefail", "-c"}, cfg.Commands[3].Shell.Args())
+}
+
+func TestNewRunnerConfig_StepWorkDirOutsideRepo(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(wd, "trx.yaml"), []byte(`
+commands:
+  - run: make
+    workdir: ../other
+`), 0o644))
+
+	_, err := NewRunnerConfig(wd, "")
+	assert.ErrorContains(t, err, "must be a relative path inside the repository")
+}
+
+func TestNewRunnerConfig_StepShellWithArgv(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(wd, "trx.yaml"), []byte(`
+commands:
+  - run: ["werf", "converge"]
+    shell: bash -c
+`), 0o644))
+
+	_, err := NewRunnerConfig(wd, "")
+	assert.ErrorContains(t, err, "shell can't be used with run specified as a list")
+}
+
+func TestNewRunnerConfig_Image(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(wd, "trx.yaml"), []byte(`
+image: alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+commands:
+  - make
+`), 0o644))
+	cfg, err := NewRunnerConfig(wd, "")
+	require.NoError(t, err)
+	assert.Equal(t, "alpine@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", cfg.Image)
+
+	require.NoError(t, os.WriteFile(filepath.Join(wd, "trx.yaml"), []byte(`
+image: alpine:3.20
+commands:
+  - make
+`), 0o644))
+	_, err = NewRunnerConfig(wd, "")
+	assert.ErrorContains(t, err, "must be pinned by digest")
+}
+
+func TestNewRunnerConfig_StepNeeds(t *testing.T) {
+	wd := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(wd, "trx.yaml"), []byte(`
+maxParallel: 2
+commands:
+  - name: docs
+    run: make docs
+    needs: []
+  - name: migrate
+    run: make migrate
+    needs: []
+  - name: deploy
+    run: make deploy
+    needs: [docs, migrate]
+  - make notify
+`), 0o644))
+
+	cfg, err := NewRunnerConfig(wd, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.MaxParallel)
+
+	deps, err := StepDeps(cfg.Commands)
+	require.NoError(t, err)
+	assert.Equal(t, [][]int{{}, {}, {0, 1}, {2}}, deps)
+}
+
+func TestStepDeps_Errors(t *testing.T) {
+	step := func(name string, needs ...string) Step {
+		return Step{Name: name, Run: Command{Script: "true"}, Needs: needs}
+	}
+
+	tests := []struct {
+		name  string
+		steps []Step
+		err   string
+	}{
+		{"unknown", []Step{step("a", "b")}, `unknown step "b"`},
+		{"self", []Step{step("a", "a")}, "can't need itself"},
+		{"duplicate", []Step{step("a"), step("a")}, "duplicate step name"},
+		{"cycle", []Step{step("a", "c"), {Name: "b", Run: Command{Script: "true"}}, step("c", "b")}, "needs form a cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := StepDeps(tt.steps)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
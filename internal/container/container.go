@@ -0,0 +1,113 @@
+package container
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+)
+
+// MountPath is the path of the checkout in the container.
+const MountPath = "/workspace"
+
+// Runtime runs commands in containers.
+type Runtime interface {
+	// Command returns the argv running the command argv in the container.
+	Command(spec Spec, args []string) ([]string, error)
+	// Kill kills the container with the name. Killing the runtime client doesn't stop the container.
+	Kill(name string) error
+}
+
+// Spec describes the container of a command.
+type Spec struct {
+	// Name is the name of the container, it is used to kill the container on cancellation.
+	Name string
+	// Image is the image reference pinned by digest.
+	Image string
+	// Checkout is the host path of the checkout mounted at MountPath.
+	Checkout string
+	// WorkDir is the host working directory inside the checkout.
+	WorkDir string
+	// Env are the names of the variables passed to the container from the env of the runtime process,
+	// so their values don't appear in the runtime argv.
+	Env []string
+}
+
+// ContainerWorkDir returns the working directory in the container.
+func (s Spec) ContainerWorkDir() (string, error) {
+	rel, err := filepath.Rel(s.Checkout, s.WorkDir)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("working directory %s is outside of the checkout %s", s.WorkDir, s.Checkout)
+	}
+	return filepath.ToSlash(filepath.Join(MountPath, rel)), nil
+}
+
+// NewName returns a unique container name.
+func NewName() string {
+	return "trx-" + uuid.NewString()
+}
+
+// CLI runs containers with a docker compatible CLI, such as docker or podman.
+type CLI struct {
+	Binary string
+}
+
+func NewRuntime(name string) (Runtime, error) {
+	switch name {
+	case "", RuntimeDocker:
+		return &CLI{Binary: RuntimeDocker}, nil
+	case RuntimePodman:
+		return &CLI{Binary: RuntimePodman}, nil
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q", name)
+	}
+}
+
+func (c *CLI) Command(spec Spec, args []string) ([]string, error) {
+	wd, err := spec.ContainerWorkDir()
+	if err != nil {
+		return nil, err
+	}
+
+	argv := []string{c.Binary, "run", "--rm", "--init"}
+	if spec.Name != "" {
+		argv = append(argv, "--name", spec.Name)
+	}
+	argv = append(argv,
+		"--volume", spec.Checkout+":"+MountPath,
+		"--workdir", wd,
+	)
+	for _, name := range spec.Env {
+		argv = append(argv, "--env", name)
+	}
+	argv = append(argv, "--entrypoint", "", spec.Image)
+	return append(argv, args...), nil
+}
+
+func (c *CLI) Kill(name string) error {
+	out, err := exec.Command(c.Binary, "kill", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s kill %s error: %w: %s", c.Binary, name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ValidateImage checks that the image reference is pinned by a sha256 digest.
+func ValidateImage(image string) error {
+	name, digest, ok := strings.Cut(image, "@")
+	if !ok || name == "" {
+		return fmt.Errorf("image %q must be pinned by digest: <name>@sha256:<digest>", image)
+	}
+	hex, ok := strings.CutPrefix(digest, "sha256:")
+	if !ok || len(hex) != 64 || strings.Trim(hex, "0123456789abcdef") != "" {
+		return fmt.Errorf("image %q has an invalid digest, a sha256 digest is required", image)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestCLI_Command(t *testing.T) {
+	runtime, err := NewRuntime(RuntimePodman)
+	require.NoError(t, err)
+
+	argv, err := runtime.Command(Spec{
+		Name:     "trx-step",
+		Image:    "registry.example.com/toolchain@" + digest,
+		Checkout: "/home/trx/.trx/repos/example.com/app",
+		WorkDir:  "/home/trx/.trx/repos/example.com/app/deploy",
+		Env:      []string{"WERF_ENV", "TOKEN"},
+	}, []string{"sh", "-c", "werf converge"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"podman", "run", "--rm", "--init", "--name", "trx-step",
+		"--volume", "/home/trx/.trx/repos/example.com/app:/workspace",
+		"--workdir", "/workspace/deploy",
+		"--env", "WERF_ENV", "--env", "TOKEN",
+		"--entrypoint", "", "registry.example.com/toolchain@" + digest,
+		"sh", "-c", "werf converge",
+	}, argv)
+
+	_, err = runtime.Command(Spec{Image: "alpine@" + digest, Checkout: "/srv/app", WorkDir: "/srv"}, []string{"true"})
+	assert.ErrorContains(t, err, "outside of the checkout")
+
+	_, err = NewRuntime("lxc")
+	assert.Error(t, err)
+}
+
+func TestCLI_Kill(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "docker")
+	require.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\necho \"$@\" > "+filepath.Join(dir, "args")+"\n"), 0o755))
+
+	runtime := &CLI{Binary: binary}
+	require.NoError(t, runtime.Kill("trx-step"))
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	assert.Equal(t, "kill trx-step\n", string(args))
+
+	runtime = &CLI{Binary: "false"}
+	assert.ErrorContains(t, runtime.Kill("trx-step"), "false kill trx-step error")
+}
+
+func TestValidateImage(t *testing.T) {
+	for _, image := range []string{
+		"alpine@" + digest,
+		"registry.example.com:5000/team/toolchain:1.2@" + digest,
+	} {
+		assert.NoError(t, ValidateImage(image), image)
+	}
+
+	for _, image := range []string{
+		"alpine",
+		"alpine:3.20",
+		"@" + digest,
+		"alpine@sha512:" + strings.Repeat("a", 128),
+		"alpine@sha256:0123",
+		"alpine@sha256:" + strings.Repeat("G", 64),
+	} {
+		assert.Error(t, ValidateImage(image), image)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"os/user"
 	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/go-git/go-git/v5"
@@ -21,6 +23,7 @@ import (
 
 type GitClient struct {
 	Repo *git.Repository
+	conf *RepoConfig
 }
 
 func NewGitClient(cfg config.GitRepo) (*GitClient, error) {
@@ -36,15 +39,19 @@ func NewGitClient(cfg config.GitRepo) (*GitClient, error) {
 
 	return &GitClient{
 		Repo: repo,
+		conf: repoConf,
 	}, nil
 }
 
-func (g *GitClient) GetTargetGitObject() (*TargetGitObject, error) {
-	tag, commit, err := g.GetLastSemverTag()
+func (g *GitClient) Fetch() error {
+	return fetchTags(g.Repo, g.conf)
+}
+
+func (g *GitClient) GetTargetGitObject(tag string) (*TargetGitObject, error) {
+	to, err := g.ResolveTargetGitObject(tag)
 	if err != nil {
 		return nil, err
 	}
-	to := &TargetGitObject{Tag: tag, Commit: commit}
 	err = g.Checkout(to)
 	if err != nil {
 		return nil, fmt.Errorf("checkout error: %w", err)
@@ -52,13 +59,117 @@ func (g *GitClient) GetTargetGitObject() (*TargetGitObject, error) {
 	return to, nil
 }
 
+// ResolveTargetGitObject resolves the given tag, or the last semver tag if no tag is given, without checkout.
+func (g *GitClient) ResolveTargetGitObject(tag string) (*TargetGitObject, error) {
+	if tag == "" {
+		lastTag, _, err := g.GetLastSemverTag()
+		if err != nil {
+			return nil, err
+		}
+		tag = lastTag
+	}
+
+	if _, err := semver.NewVersion(tag); err != nil {
+		return nil, fmt.Errorf("tag %s is not a semantic version: %w", tag, err)
+	}
+
+	ref, err := g.Repo.Tag(tag)
+	if err != nil {
+		return nil, fmt.Errorf("tag %s not found: %w", tag, err)
+	}
+
+	return g.targetGitObject(tag, ref)
+}
+
+// targetGitObject returns the tag with the commit it points to and their metadata.
+func (g *GitClient) targetGitObject(tag string, ref *plumbing.Reference) (*TargetGitObject, error) {
+	o := &TargetGitObject{Tag: tag}
+
+	var commit *object.Commit
+	tagObj, err := g.Repo.TagObject(ref.Hash())
+	switch {
+	case err == nil:
+		o.TagMessage = strings.TrimSpace(tagObj.Message)
+		o.TagDate = tagObj.Tagger.When
+		commit, err = tagObj.Commit()
+	case errors.Is(err, plumbing.ErrObjectNotFound):
+		commit, err = g.Repo.CommitObject(ref.Hash())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get commit of tag %s: %w", tag, err)
+	}
+	o.Commit = commit.Hash.String()
+	o.CommitAuthor = fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email)
+	o.CommitMessage = strings.TrimSpace(commit.Message)
+	if o.TagDate.IsZero() {
+		o.TagDate = commit.Committer.When
+	}
+	return o, nil
+}
+
+type TagRetargetedError struct {
+	Tag            string
+	ExpectedCommit string
+	ActualCommit   string
+}
+
+func (e *TagRetargetedError) Error() string {
+	return fmt.Sprintf("tag %s was moved: processed at commit %s, now points to commit %s", e.Tag, e.ExpectedCommit, e.ActualCommit)
+}
+
+// CheckTagCommits ensures that the already processed tags still point to the same commits.
+func (g *GitClient) CheckTagCommits(known map[string]string) error {
+	for tag, expected := range known {
+		ref, err := g.Repo.Tag(tag)
+		if err != nil {
+			if errors.Is(err, git.ErrTagNotFound) {
+				log.Printf("WARNING processed tag %s not found in repository", tag)
+				continue
+			}
+			return fmt.Errorf("unable to get tag %s: %w", tag, err)
+		}
+
+		actual, err := g.tagCommit(ref)
+		if err != nil {
+			return err
+		}
+		if actual != expected {
+			return &TagRetargetedError{Tag: tag, ExpectedCommit: expected, ActualCommit: actual}
+		}
+	}
+	return nil
+}
+
+// tagCommit returns the commit hash the tag reference points to, peeling annotated tags.
+func (g *GitClient) tagCommit(ref *plumbing.Reference) (string, error) {
+	tagObj, err := g.Repo.TagObject(ref.Hash())
+	switch {
+	case errors.Is(err, plumbing.ErrObjectNotFound):
+		return ref.Hash().String(), nil
+	case err != nil:
+		return "", fmt.Errorf("unable to get tag object %s: %w", ref.Name().Short(), err)
+	}
+
+	commit, err := tagObj.Commit()
+	if err != nil {
+		return "", fmt.Errorf("unable to get commit of tag %s: %w", ref.Name().Short(), err)
+	}
+	return commit.Hash.String(), nil
+}
+
 type TargetGitObject struct {
 	Tag    string
 	Commit string
+
+	// TagMessage is empty for lightweight tags, and TagDate is the commit date for them.
+	TagMessage    string
+	TagDate       time.Time
+	CommitAuthor  string
+	CommitMessage string
 }
 
 func (g *GitClient) Checkout(o *TargetGitObject) error {
-	log.Printf("Got last tag %s. Perform checkout\n", o.Tag)
+	log.Printf("Got tag %s. Perform checkout\n", o.Tag)
 	tagRef, err := g.Repo.Tag(o.Tag)
 	if err != nil {
 		return fmt.Errorf("tag not found: %w", err)
@@ -122,19 +233,74 @@ func (g *GitClient) GetLastSemverTag() (string, string, error) {
 		return "", "", err
 	}
 
-	hash := ref.Hash()
+	commit, err := g.tagCommit(ref)
+	if err != nil {
+		return "", "", err
+	}
 
-	return lastTag, hash.String(), nil
+	return lastTag, commit, nil
 }
 
-func openGitRepo(r *RepoConfig) (*git.Repository, error) {
+// ClonePath returns the directory where the repository is cloned.
+func ClonePath(repoUrl string) (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".trx", "repos", RepoKeyFromUrl(repoUrl)), nil
+}
+
+// LegacyClonePath returns the directory where the repository was cloned before the clone path included the repository host and path.
+func LegacyClonePath(repoUrl string) (string, error) {
 	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".trx", RepoNameFromUrl(repoUrl)), nil
+}
+
+// IsCloneOf reports whether the directory contains a clone of the repository with the given URL.
+func IsCloneOf(path, repoUrl string) bool {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return false
+	}
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return false
+	}
+	urls := remote.Config().URLs
+	return len(urls) > 0 && urls[0] == repoUrl
+}
+
+func migrateLegacyClone(repoUrl, repoPath string) error {
+	legacyPath, err := LegacyClonePath(repoUrl)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(repoPath); !os.IsNotExist(err) {
+		return nil
+	}
+	if !IsCloneOf(legacyPath, repoUrl) {
+		return nil
+	}
+
+	log.Printf("Moving repository clone from %s to %s\n", legacyPath, repoPath)
+	if err := os.MkdirAll(filepath.Dir(repoPath), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(legacyPath, repoPath)
+}
+
+func openGitRepo(r *RepoConfig) (*git.Repository, error) {
+	repoPath, err := ClonePath(r.Url)
 	if err != nil {
 		return nil, err
 	}
 
-	repoName := RepoNameFromUrl(r.Url)
-	repoPath := filepath.Join(usr.HomeDir, ".trx", repoName)
+	if err := migrateLegacyClone(r.Url, repoPath); err != nil {
+		return nil, fmt.Errorf("unable to migrate legacy repo clone: %w", err)
+	}
 
 	var repo *git.Repository
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
@@ -157,6 +323,14 @@ func openGitRepo(r *RepoConfig) (*git.Repository, error) {
 	}
 
 	command.WorkDir = repoPath
+	if err := fetchTags(repo, r); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+func fetchTags(repo *git.Repository, r *RepoConfig) error {
 	log.Println("Fetching tags")
 	fetchOptions := &git.FetchOptions{
 		RefSpecs: []gitconfig.RefSpec{
@@ -166,10 +340,9 @@ func openGitRepo(r *RepoConfig) (*git.Repository, error) {
 	if r.Auth != nil {
 		fetchOptions.Auth = r.Auth.AuthMethod
 	}
-	err = repo.Fetch(fetchOptions)
+	err := repo.Fetch(fetchOptions)
 	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
-		return nil, fmt.Errorf("unable to fetch tags: %w", err)
+		return fmt.Errorf("unable to fetch tags: %w", err)
 	}
-
-	return repo, nil
+	return nil
 }
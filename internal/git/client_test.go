@@ -0,0 +1,67 @@
+package git
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTagCommits(t *testing.T) {
+	repo := newTestRepo(t)
+	head, err := repo.Head()
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1.0.0", head.Hash(), &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "v1.0.0",
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1.1.0", head.Hash(), nil)
+	require.NoError(t, err)
+
+	g := &GitClient{Repo: repo}
+	commit := head.Hash().String()
+
+	assert.NoError(t, g.CheckTagCommits(map[string]string{"v1.0.0": commit, "v1.1.0": commit, "v0.9.0": "deleted"}))
+
+	err = g.CheckTagCommits(map[string]string{"v1.0.0": "0000000000000000000000000000000000000000"})
+	var retargetErr *TagRetargetedError
+	require.True(t, errors.As(err, &retargetErr))
+	assert.Equal(t, "v1.0.0", retargetErr.Tag)
+	assert.Equal(t, commit, retargetErr.ActualCommit)
+}
+
+func TestResolveTargetGitObject(t *testing.T) {
+	repo := newTestRepo(t)
+	head, err := repo.Head()
+	require.NoError(t, err)
+	tagDate := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, err = repo.CreateTag("v1.0.0", head.Hash(), &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "releaser", Email: "releaser@example.com", When: tagDate},
+		Message: "Release v1.0.0\n",
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1.1.0", head.Hash(), nil)
+	require.NoError(t, err)
+
+	g := &GitClient{Repo: repo}
+
+	o, err := g.ResolveTargetGitObject("v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, head.Hash().String(), o.Commit)
+	assert.Equal(t, "Release v1.0.0", o.TagMessage)
+	assert.True(t, tagDate.Equal(o.TagDate))
+	assert.Equal(t, "test <test@example.com>", o.CommitAuthor)
+	assert.Equal(t, "initial", o.CommitMessage)
+
+	o, err = g.ResolveTargetGitObject("")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", o.Tag)
+	assert.Equal(t, head.Hash().String(), o.Commit)
+	assert.Equal(t, "", o.TagMessage)
+	assert.False(t, o.TagDate.IsZero())
+}
@@ -1,12 +1,17 @@
 package git
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/go-git/go-git/v5"
 	"github.com/hashicorp/go-hclog"
 	trdlGit "github.com/werf/trdl/server/pkg/git"
@@ -27,10 +32,71 @@ func VerifyTagSignatures(repo *git.Repository, r VerifyTagSignaturesRequest) err
 	return nil
 }
 
+// TagSigners returns fingerprints of the given GPG keys whose signatures of the tag are valid.
+func TagSigners(repo *git.Repository, tag string, gpgKeys []string) ([]string, error) {
+	var signers []string
+	for _, key := range gpgKeys {
+		fingerprint, err := keyFingerprint(key)
+		if err != nil {
+			return nil, err
+		}
+
+		err = trdlGit.VerifyTagSignatures(repo, tag, []string{key}, 1, logger())
+		var notEnoughErr *trdlGit.NotEnoughVerifiedPGPSignaturesError
+		switch {
+		case err == nil:
+			signers = append(signers, fingerprint)
+		case errors.As(err, &notEnoughErr):
+		default:
+			return nil, fmt.Errorf("unable to verify tag signature with key %s: %w", fingerprint, err)
+		}
+	}
+	return signers, nil
+}
+
+func keyFingerprint(key string) (string, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+	if err != nil {
+		return "", fmt.Errorf("unable to read GPG key: %w", err)
+	}
+	if len(entities) == 0 || entities[0].PrimaryKey == nil {
+		return "", fmt.Errorf("no GPG public key found")
+	}
+	return strings.ToUpper(hex.EncodeToString(entities[0].PrimaryKey.Fingerprint)), nil
+}
+
 func RepoNameFromUrl(url string) string {
 	return strings.TrimSuffix(path.Base(url), ".git")
 }
 
+// RepoKeyFromUrl returns a relative path built from the host and the path of the repository URL,
+// e.g. `github.com/werf/werf` for both `git@github.com:werf/werf.git` and `https://github.com/werf/werf.git`.
+func RepoKeyFromUrl(repoUrl string) string {
+	var host, repoPath string
+	if u, err := url.Parse(repoUrl); err == nil && u.Host != "" {
+		host, repoPath = u.Host, u.Path
+	} else if i := strings.Index(repoUrl, ":"); i >= 0 {
+		host, repoPath = repoUrl[:i], repoUrl[i+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	} else {
+		repoPath = repoUrl
+	}
+
+	var parts []string
+	if host != "" {
+		parts = append(parts, strings.ReplaceAll(strings.ToLower(host), ":", "_"))
+	}
+	for _, p := range strings.Split(strings.TrimSuffix(repoPath, ".git"), "/") {
+		if p == "" || p == "." || p == ".." {
+			continue
+		}
+		parts = append(parts, p)
+	}
+	return filepath.Join(parts...)
+}
+
 func IsNewerVersion(current, last, initial string) (bool, error) {
 	currentVer, err := semver.NewVersion(current)
 	if err != nil {
@@ -83,3 +83,18 @@ func TestCheckNewVersion_unhappyPath(t *testing.T) {
 		assert.False(t, b)
 	}
 }
+
+func TestRepoKeyFromUrl(t *testing.T) {
+	tcs := map[string]string{
+		"git@github.com:werf/werf.git":            "github.com/werf/werf",
+		"https://github.com/werf/werf.git":        "github.com/werf/werf",
+		"git@a.example.com:team1/app.git":         "a.example.com/team1/app",
+		"git@b.example.com:team2/app.git":         "b.example.com/team2/app",
+		"https://GitLab.example.com:8443/g/a.git": "gitlab.example.com_8443/g/a",
+		"https://example.com/../../etc/app.git":   "example.com/etc/app",
+	}
+
+	for u, expected := range tcs {
+		assert.Equal(t, expected, RepoKeyFromUrl(u), u)
+	}
+}
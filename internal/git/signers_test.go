@@ -0,0 +1,69 @@
+package git
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagSigners(t *testing.T) {
+	signer := newTestEntity(t, "signer")
+	other := newTestEntity(t, "other")
+
+	repo := newTestRepo(t)
+	head, err := repo.Head()
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1.0.0", head.Hash(), &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "v1.0.0",
+		SignKey: signer,
+	})
+	require.NoError(t, err)
+
+	signers, err := TagSigners(repo, "v1.0.0", []string{armoredPublicKey(t, signer), armoredPublicKey(t, other)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{strings.ToUpper(hex.EncodeToString(signer.PrimaryKey.Fingerprint))}, signers)
+}
+
+func newTestRepo(t *testing.T) *git.Repository {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Commit("initial", &git.CommitOptions{
+		AllowEmptyCommits: true,
+		Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return repo
+}
+
+func newTestEntity(t *testing.T, name string) *openpgp.Entity {
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", &packet.Config{
+		Algorithm: packet.PubKeyAlgoRSA,
+		RSABits:   2048,
+	})
+	require.NoError(t, err)
+	return e
+}
+
+func armoredPublicKey(t *testing.T, e *openpgp.Entity) string {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, e.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.String()
+}
@@ -0,0 +1,187 @@
+package history
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	KindRun      = "run"
+	KindRollback = "rollback"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusSkipped   = "skipped"
+)
+
+type Record struct {
+	ID         string         `json:"id"`
+	Kind       string         `json:"kind"`
+	Tag        string         `json:"tag"`
+	FromTag    string         `json:"fromTag,omitempty"`
+	Commit     string         `json:"commit"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	Commands   []string       `json:"commands,omitempty"`
+	Status     string         `json:"status"`
+	ExitCode   int            `json:"exitCode"`
+	Error      string         `json:"error,omitempty"`
+	Quorums    []QuorumRecord `json:"quorums,omitempty"`
+	Steps      []StepRecord   `json:"steps,omitempty"`
+}
+
+type QuorumRecord struct {
+	Name    string   `json:"name"`
+	Passed  bool     `json:"passed"`
+	Signers []string `json:"signers,omitempty"`
+}
+
+type StepRecord struct {
+	Name       string    `json:"name"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Attempts   int       `json:"attempts"`
+	Status     string    `json:"status"`
+	ExitCode   int       `json:"exitCode"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func NewStepRecord(name string, startedAt, finishedAt time.Time, attempts int, err error) StepRecord {
+	r := StepRecord{
+		Name:       name,
+		StartedAt:  startedAt,
+		FinishedAt: finishedAt,
+		Attempts:   attempts,
+		Status:     StatusSucceeded,
+	}
+	if err != nil {
+		r.Status, r.ExitCode, r.Error = errorStatus(err), exitCode(err), err.Error()
+	}
+	return r
+}
+
+// NewSkippedStepRecord returns the record of a step not run because an earlier step failed or the run was cancelled.
+func NewSkippedStepRecord(name string) StepRecord {
+	return StepRecord{Name: name, Status: StatusSkipped}
+}
+
+func NewRecord(kind, id, tag, commit string) *Record {
+	return &Record{
+		ID:        id,
+		Kind:      kind,
+		Tag:       tag,
+		Commit:    commit,
+		StartedAt: time.Now(),
+	}
+}
+
+// Finish sets the finish time and the status of the record according to the run error.
+// A run interrupted with the cancellation of its context is recorded as cancelled.
+func (r *Record) Finish(err error) {
+	r.FinishedAt = time.Now()
+	if err == nil {
+		r.Status = StatusSucceeded
+		r.ExitCode = 0
+		return
+	}
+
+	r.Status = errorStatus(err)
+	r.Error = err.Error()
+	r.ExitCode = exitCode(err)
+}
+
+func errorStatus(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return StatusCancelled
+	}
+	return StatusFailed
+}
+
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+func (r *Record) Succeeded() bool {
+	return r.Status == StatusSucceeded
+}
+
+type Query struct {
+	Kind   string
+	Tag    string
+	Status string
+	Limit  int
+}
+
+// Filter returns records matching the query, keeping the order. If the limit is set, only the most recent records are returned.
+func Filter(records []Record, q Query) []Record {
+	var res []Record
+	for _, r := range records {
+		if q.Kind != "" && r.Kind != q.Kind {
+			continue
+		}
+		if q.Tag != "" && r.Tag != q.Tag {
+			continue
+		}
+		if q.Status != "" && r.Status != q.Status {
+			continue
+		}
+		res = append(res, r)
+	}
+	if q.Limit > 0 && len(res) > q.Limit {
+		res = res[len(res)-q.Limit:]
+	}
+	return res
+}
+
+func NewRunID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// CurrentTag returns the tag of the last successful run or rollback.
+func CurrentTag(records []Record) string {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Succeeded() {
+			return records[i].Tag
+		}
+	}
+	return ""
+}
+
+// PreviousTag returns the tag of the most recent successful run that is older than the given tag.
+func PreviousTag(records []Record, tag string) (string, error) {
+	current, err := semver.NewVersion(tag)
+	if err != nil {
+		return "", fmt.Errorf("invalid tag %s: %w", tag, err)
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		if r.Kind != KindRun || !r.Succeeded() {
+			continue
+		}
+		v, err := semver.NewVersion(r.Tag)
+		if err != nil {
+			continue
+		}
+		if v.LessThan(current) {
+			return r.Tag, nil
+		}
+	}
+	return "", fmt.Errorf("no successfully processed tag older than %s found in history", tag)
+}
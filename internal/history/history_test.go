@@ -0,0 +1,65 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviousTag(t *testing.T) {
+	records := []Record{
+		{Kind: KindRun, Tag: "v1.0.0", Status: StatusSucceeded},
+		{Kind: KindRun, Tag: "v1.1.0", Status: StatusSucceeded},
+		{Kind: KindRun, Tag: "v1.2.0", Status: StatusSucceeded},
+		{Kind: KindRollback, Tag: "v1.1.0", FromTag: "v1.2.0", Status: StatusSucceeded},
+		{Kind: KindRun, Tag: "v1.3.0", Status: StatusFailed},
+	}
+
+	assert.Equal(t, "v1.1.0", CurrentTag(records))
+
+	tag, err := PreviousTag(records, CurrentTag(records))
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.0.0", tag)
+
+	_, err = PreviousTag(records, "v1.0.0")
+	assert.Error(t, err)
+}
+
+func TestFilter(t *testing.T) {
+	records := []Record{
+		{ID: "1", Kind: KindRun, Tag: "v1.0.0", Status: StatusSucceeded},
+		{ID: "2", Kind: KindRun, Tag: "v1.1.0", Status: StatusFailed},
+		{ID: "3", Kind: KindRun, Tag: "v1.1.0", Status: StatusSucceeded},
+		{ID: "4", Kind: KindRollback, Tag: "v1.0.0", Status: StatusSucceeded},
+	}
+
+	ids := func(rs []Record) []string {
+		var res []string
+		for _, r := range rs {
+			res = append(res, r.ID)
+		}
+		return res
+	}
+
+	assert.Equal(t, []string{"1", "2", "3", "4"}, ids(Filter(records, Query{})))
+	assert.Equal(t, []string{"2", "3"}, ids(Filter(records, Query{Tag: "v1.1.0"})))
+	assert.Equal(t, []string{"1", "3", "4"}, ids(Filter(records, Query{Status: StatusSucceeded})))
+	assert.Equal(t, []string{"3", "4"}, ids(Filter(records, Query{Status: StatusSucceeded, Limit: 2})))
+	assert.Equal(t, []string{"4"}, ids(Filter(records, Query{Kind: KindRollback})))
+}
+
+func TestRecordFinish(t *testing.T) {
+	r := NewRecord(KindRun, "1", "v1.0.0", "abc")
+	r.Finish(nil)
+	assert.Equal(t, StatusSucceeded, r.Status)
+
+	r.Finish(errors.New("boom"))
+	assert.Equal(t, StatusFailed, r.Status)
+	assert.Equal(t, 1, r.ExitCode)
+
+	r.Finish(fmt.Errorf("run command cancelled: %w", context.Canceled))
+	assert.Equal(t, StatusCancelled, r.Status)
+}
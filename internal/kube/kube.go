@@ -0,0 +1,58 @@
+package kube
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"trx/internal/config"
+	"trx/internal/git"
+)
+
+// NewClient creates a Kubernetes client and returns it with the namespace to use.
+// The in-cluster configuration is used if no kubeconfig is found.
+func NewClient(cfg config.Kubernetes) (kubernetes.Interface, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = cfg.Kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{
+		CurrentContext: cfg.Context,
+	})
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to get kubernetes client config: %w", err)
+	}
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace, _, err = clientConfig.Namespace()
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to get kubernetes namespace: %w", err)
+		}
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create kubernetes client: %w", err)
+	}
+	return client, namespace, nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// ResourceName returns a valid Kubernetes resource name unique for the repository, e.g. `trx-werf-0123456789`.
+func ResourceName(repoUrl string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(git.RepoNameFromUrl(repoUrl)), "-")
+	if len(name) > 40 {
+		name = name[:40]
+	}
+	name = strings.Trim(name, "-")
+
+	sum := sha256.Sum256([]byte(git.RepoKeyFromUrl(repoUrl)))
+	return fmt.Sprintf("trx-%s-%s", name, hex.EncodeToString(sum[:])[:10])
+}
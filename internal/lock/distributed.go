@@ -0,0 +1,54 @@
+package lock
+
+import (
+	"log"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+)
+
+// DistributedBackend is a distributed locker backend that also keeps the holder metadata.
+type DistributedBackend interface {
+	distributed_locker.DistributedLockerBackend
+	SetHolder(handle lockgate.LockHandle, holder Holder) error
+	Status(lockName string) (*Status, error)
+	Break(lockName string) error
+}
+
+// Distributed is a locker shared between hosts. The lease is renewed in background while the lock is held.
+type Distributed struct {
+	locker  *distributed_locker.DistributedLocker
+	backend DistributedBackend
+}
+
+func NewDistributedLocker(backend DistributedBackend) *Distributed {
+	return &Distributed{locker: distributed_locker.NewDistributedLocker(backend), backend: backend}
+}
+
+func (d *Distributed) Acquire(lockName string, opts lockgate.AcquireOptions) (bool, lockgate.LockHandle, error) {
+	onLost := opts.OnLostLeaseFunc
+	opts.OnLostLeaseFunc = func(lock lockgate.LockHandle) error {
+		log.Printf("WARNING lost execution lock %s", lock.LockName)
+		if onLost != nil {
+			return onLost(lock)
+		}
+		return nil
+	}
+	return d.locker.Acquire(lockName, opts)
+}
+
+func (d *Distributed) Release(lock lockgate.LockHandle) error {
+	return d.locker.Release(lock)
+}
+
+func (d *Distributed) SetHolder(lock lockgate.LockHandle, holder Holder) error {
+	return d.backend.SetHolder(lock, holder)
+}
+
+func (d *Distributed) Status(lockName string) (*Status, error) {
+	return d.backend.Status(lockName)
+}
+
+func (d *Distributed) Break(lockName string) error {
+	return d.backend.Break(lockName)
+}
@@ -0,0 +1,216 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"trx/internal/kube"
+)
+
+const annotationHolder = "trx.flant.com/holder"
+
+// KubernetesLeaseBackend is a distributed locker backend that holds locks with coordination.k8s.io Leases.
+type KubernetesLeaseBackend struct {
+	client    kubernetes.Interface
+	namespace string
+	leaseName string
+	ttl       time.Duration
+}
+
+// NewKubernetesLeaseBackend creates the backend. If the lease name is empty, it is derived from the lock name.
+func NewKubernetesLeaseBackend(client kubernetes.Interface, namespace, leaseName string, ttl time.Duration) *KubernetesLeaseBackend {
+	return &KubernetesLeaseBackend{
+		client:    client,
+		namespace: namespace,
+		leaseName: leaseName,
+		ttl:       ttl,
+	}
+}
+
+func (b *KubernetesLeaseBackend) Acquire(lockName string, _ distributed_locker.AcquireOptions) (lockgate.LockHandle, error) {
+	ctx := context.Background()
+	handle := lockgate.LockHandle{UUID: uuid.New().String(), LockName: lockName}
+	name := b.name(lockName)
+	now := metav1.NewMicroTime(time.Now())
+
+	lease, err := b.client.CoordinationV1().Leases(b.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: b.namespace}}
+		b.setLeaseHolder(lease, handle.UUID, now)
+		_, err = b.client.CoordinationV1().Leases(b.namespace).Create(ctx, lease, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return lockgate.LockHandle{}, distributed_locker.ErrShouldWait
+		}
+		if err != nil {
+			return lockgate.LockHandle{}, fmt.Errorf("unable to create lease %s: %w", name, err)
+		}
+		return handle, nil
+	}
+	if err != nil {
+		return lockgate.LockHandle{}, fmt.Errorf("unable to get lease %s: %w", name, err)
+	}
+
+	if isLeaseHeld(lease, now.Time) {
+		return lockgate.LockHandle{}, distributed_locker.ErrShouldWait
+	}
+
+	b.setLeaseHolder(lease, handle.UUID, now)
+	_, err = b.client.CoordinationV1().Leases(b.namespace).Update(ctx, lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return lockgate.LockHandle{}, distributed_locker.ErrShouldWait
+	}
+	if err != nil {
+		return lockgate.LockHandle{}, fmt.Errorf("unable to update lease %s: %w", name, err)
+	}
+	return handle, nil
+}
+
+func (b *KubernetesLeaseBackend) RenewLease(handle lockgate.LockHandle) error {
+	return b.changeLease(handle, func(lease *coordinationv1.Lease) {
+		lease.Spec.RenewTime = ptr(metav1.NewMicroTime(time.Now()))
+	})
+}
+
+func (b *KubernetesLeaseBackend) Release(handle lockgate.LockHandle) error {
+	return b.changeLease(handle, clearLeaseHolder)
+}
+
+func (b *KubernetesLeaseBackend) SetHolder(handle lockgate.LockHandle, holder Holder) error {
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return fmt.Errorf("unable to marshal lock holder: %w", err)
+	}
+	return b.changeLease(handle, func(lease *coordinationv1.Lease) {
+		if lease.Annotations == nil {
+			lease.Annotations = make(map[string]string)
+		}
+		lease.Annotations[annotationHolder] = string(data)
+	})
+}
+
+func (b *KubernetesLeaseBackend) Status(lockName string) (*Status, error) {
+	name := b.name(lockName)
+	lease, err := b.client.CoordinationV1().Leases(b.namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return &Status{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get lease %s: %w", name, err)
+	}
+
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" {
+		return &Status{}, nil
+	}
+
+	status := &Status{Locked: true, Stale: !isLeaseHeld(lease, time.Now())}
+	if spec.RenewTime != nil && spec.LeaseDurationSeconds != nil {
+		status.ExpiresAt = spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+	}
+	if data, ok := lease.Annotations[annotationHolder]; ok {
+		holder := &Holder{}
+		if err := json.Unmarshal([]byte(data), holder); err != nil {
+			return nil, fmt.Errorf("unable to parse holder of lease %s: %w", name, err)
+		}
+		status.Holder = holder
+	}
+	return status, nil
+}
+
+func (b *KubernetesLeaseBackend) Break(lockName string) error {
+	ctx := context.Background()
+	name := b.name(lockName)
+	lease, err := b.client.CoordinationV1().Leases(b.namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get lease %s: %w", name, err)
+	}
+
+	clearLeaseHolder(lease)
+	if _, err := b.client.CoordinationV1().Leases(b.namespace).Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update lease %s: %w", name, err)
+	}
+	return nil
+}
+
+// changeLease updates the lease held with the handle, retrying if the lease is changed concurrently by the same holder.
+func (b *KubernetesLeaseBackend) changeLease(handle lockgate.LockHandle, change func(lease *coordinationv1.Lease)) error {
+	ctx := context.Background()
+	name := b.name(handle.LockName)
+
+	for i := 0; ; i++ {
+		lease, err := b.client.CoordinationV1().Leases(b.namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return distributed_locker.ErrNoExistingLockLeaseFound
+		}
+		if err != nil {
+			return fmt.Errorf("unable to get lease %s: %w", name, err)
+		}
+
+		switch {
+		case lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "":
+			return distributed_locker.ErrNoExistingLockLeaseFound
+		case *lease.Spec.HolderIdentity != handle.UUID:
+			return distributed_locker.ErrLockAlreadyLeased
+		}
+
+		change(lease)
+		_, err = b.client.CoordinationV1().Leases(b.namespace).Update(ctx, lease, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			if i < maxChangeRetries {
+				continue
+			}
+			return distributed_locker.ErrLockAlreadyLeased
+		}
+		if err != nil {
+			return fmt.Errorf("unable to update lease %s: %w", name, err)
+		}
+		return nil
+	}
+}
+
+func (b *KubernetesLeaseBackend) name(lockName string) string {
+	if b.leaseName != "" {
+		return b.leaseName
+	}
+	return kube.ResourceName(lockName)
+}
+
+func isLeaseHeld(lease *coordinationv1.Lease, now time.Time) bool {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	return now.Before(spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second))
+}
+
+func (b *KubernetesLeaseBackend) setLeaseHolder(lease *coordinationv1.Lease, holder string, now metav1.MicroTime) {
+	delete(lease.Annotations, annotationHolder)
+	lease.Spec.HolderIdentity = ptr(holder)
+	lease.Spec.LeaseDurationSeconds = ptr(int32((b.ttl + time.Second - 1) / time.Second))
+	lease.Spec.AcquireTime = ptr(now)
+	lease.Spec.RenewTime = ptr(now)
+}
+
+func clearLeaseHolder(lease *coordinationv1.Lease) {
+	delete(lease.Annotations, annotationHolder)
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.AcquireTime = nil
+	lease.Spec.RenewTime = nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,71 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubernetesLeaseBackend(t *testing.T) {
+	client := fake.NewClientset()
+	first := NewKubernetesLeaseBackend(client, "default", "trx-lock", DefaultLeaseTTL)
+	second := NewKubernetesLeaseBackend(client, "default", "trx-lock", DefaultLeaseTTL)
+
+	handle, err := first.Acquire("repo", distributed_locker.AcquireOptions{})
+	require.NoError(t, err)
+
+	_, err = second.Acquire("repo", distributed_locker.AcquireOptions{})
+	assert.ErrorIs(t, err, distributed_locker.ErrShouldWait)
+
+	require.NoError(t, first.SetHolder(handle, Holder{PID: 42, Host: "runner-1", Tag: "v1.0.0"}))
+	require.NoError(t, first.RenewLease(handle))
+
+	status, err := second.Status("repo")
+	require.NoError(t, err)
+	assert.True(t, status.Locked)
+	assert.False(t, status.Stale)
+	require.NotNil(t, status.Holder)
+	assert.Equal(t, 42, status.Holder.PID)
+
+	require.NoError(t, first.Release(handle))
+	assert.ErrorIs(t, first.RenewLease(handle), distributed_locker.ErrNoExistingLockLeaseFound)
+
+	otherHandle, err := second.Acquire("repo", distributed_locker.AcquireOptions{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, first.Release(handle), distributed_locker.ErrLockAlreadyLeased)
+	require.NoError(t, second.Release(otherHandle))
+}
+
+func TestKubernetesLeaseBackend_ExpiredLease(t *testing.T) {
+	client := fake.NewClientset()
+	first := NewKubernetesLeaseBackend(client, "default", "trx-lock", DefaultLeaseTTL)
+	second := NewKubernetesLeaseBackend(client, "default", "trx-lock", DefaultLeaseTTL)
+
+	_, err := first.Acquire("repo", distributed_locker.AcquireOptions{})
+	require.NoError(t, err)
+
+	leases := client.CoordinationV1().Leases("default")
+	lease, err := leases.Get(context.Background(), "trx-lock", metav1.GetOptions{})
+	require.NoError(t, err)
+	lease.Spec.RenewTime = ptr(metav1.NewMicroTime(time.Now().Add(-time.Minute)))
+	_, err = leases.Update(context.Background(), lease, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	status, err := second.Status("repo")
+	require.NoError(t, err)
+	assert.True(t, status.Stale)
+
+	require.NoError(t, second.Break("repo"))
+	status, err = second.Status("repo")
+	require.NoError(t, err)
+	assert.False(t, status.Locked)
+
+	_, err = second.Acquire("repo", distributed_locker.AcquireOptions{})
+	require.NoError(t, err)
+}
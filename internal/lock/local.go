@@ -1,22 +1,109 @@
 package lock
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+
 	lock "github.com/werf/common-go/pkg/lock"
 	"github.com/werf/lockgate"
+
+	"trx/internal/git"
 )
 
+// Local is a host lock. The holder metadata is kept in `~/.trx/locks/<host>/<path>.json` while the lock is held.
 type Local struct {
-	locker   Locker
-	disabled bool
+	locker   lockgate.Locker
+	locksDir string
 }
 
-func NewLocalLocker(disabled bool) *Local {
-	locker, _ := lock.HostLocker()
-	return &Local{locker: locker, disabled: disabled}
+func NewLocalLocker() (*Local, error) {
+	locker, err := lock.HostLocker()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get host locker: %w", err)
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	return &Local{locker: locker, locksDir: filepath.Join(usr.HomeDir, ".trx", "locks")}, nil
 }
 
 func (l *Local) Acquire(lockName string, opts lockgate.AcquireOptions) (bool, lockgate.LockHandle, error) {
-	return l.locker.Acquire(lockName, lockgate.AcquireOptions{
-		NonBlocking: l.disabled,
-	})
+	return l.locker.Acquire(lockName, opts)
+}
+
+func (l *Local) Release(lock lockgate.LockHandle) error {
+	if err := os.Remove(l.holderPath(lock.LockName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove lock holder file: %w", err)
+	}
+	return l.locker.Release(lock)
+}
+
+func (l *Local) SetHolder(lock lockgate.LockHandle, holder Holder) error {
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return fmt.Errorf("unable to marshal lock holder: %w", err)
+	}
+	path := l.holderPath(lock.LockName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Status reports the host lock state. Host locks are released when the holding process exits,
+// so a lock is stale if only the holder file left by a crashed run remains.
+func (l *Local) Status(lockName string) (*Status, error) {
+	holder, err := l.readHolder(lockName)
+	if err != nil {
+		return nil, err
+	}
+
+	acquired, handle, err := l.locker.Acquire(lockName, lockgate.AcquireOptions{NonBlocking: true})
+	if err != nil {
+		return nil, fmt.Errorf("unable to check lock: %w", err)
+	}
+	if acquired {
+		if err := l.locker.Release(handle); err != nil {
+			return nil, fmt.Errorf("unable to check lock: %w", err)
+		}
+	}
+
+	return &Status{Locked: !acquired || holder != nil, Stale: acquired && holder != nil, Holder: holder}, nil
+}
+
+func (l *Local) Break(lockName string) error {
+	status, err := l.Status(lockName)
+	if err != nil {
+		return err
+	}
+	if status.Locked && !status.Stale {
+		return fmt.Errorf("lock is held by a running process and is released when the process exits")
+	}
+	if err := os.Remove(l.holderPath(lockName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove lock holder file: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) readHolder(lockName string) (*Holder, error) {
+	data, err := os.ReadFile(l.holderPath(lockName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read lock holder file: %w", err)
+	}
+	holder := &Holder{}
+	if err := json.Unmarshal(data, holder); err != nil {
+		return nil, fmt.Errorf("unable to parse lock holder file: %w", err)
+	}
+	return holder, nil
+}
+
+func (l *Local) holderPath(lockName string) string {
+	return filepath.Join(l.locksDir, filepath.FromSlash(git.RepoKeyFromUrl(lockName))+".json")
 }
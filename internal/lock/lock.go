@@ -1,21 +1,250 @@
 package lock
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
 	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+
+	"trx/internal/config"
+	"trx/internal/kube"
+)
+
+const (
+	TypeLocal      = "local"
+	TypeKubernetes = "kubernetes"
+	TypeS3         = "s3"
+)
+
+const (
+	OnBusyWait = "wait"
+	OnBusySkip = "skip"
+	OnBusyFail = "fail"
+)
+
+const busyPollPeriod = time.Second
+
+// DefaultLeaseTTL is the time a distributed lock is held without renewal.
+const DefaultLeaseTTL = distributed_locker.DistributedLockLeaseTTLSeconds * time.Second
+
+var (
+	ErrBusy = errors.New("lock is held by another run")
+	// ErrLost is the cause of the run cancellation when the distributed lock lease is lost.
+	ErrLost = errors.New("execution lock lease is lost")
 )
 
 type Locker interface {
 	Acquire(lockName string, opts lockgate.AcquireOptions) (bool, lockgate.LockHandle, error)
+	Release(lock lockgate.LockHandle) error
+	SetHolder(lock lockgate.LockHandle, holder Holder) error
+	Status(lockName string) (*Status, error)
+	Break(lockName string) error
+}
+
+// Holder describes the run holding the lock.
+type Holder struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Tag       string    `json:"tag,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+func NewHolder(tag string) Holder {
+	host, _ := os.Hostname()
+	return Holder{PID: os.Getpid(), Host: host, Tag: tag, StartedAt: time.Now()}
 }
+
+func (h *Holder) String() string {
+	s := fmt.Sprintf("pid %d on host %s since %s", h.PID, h.Host, h.StartedAt.Format(time.RFC3339))
+	if h.Tag != "" {
+		s += fmt.Sprintf(" for tag %s", h.Tag)
+	}
+	return s
+}
+
+// Status describes the current state of the lock.
+// A lock is stale if it is still recorded as held, but its holder is gone.
+type Status struct {
+	Locked    bool      `json:"locked"`
+	Stale     bool      `json:"stale"`
+	Holder    *Holder   `json:"holder,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+type Options struct {
+	Timeout  time.Duration
+	OnBusy   string
+	Disabled bool
+}
+
 type Manager struct {
-	locker Locker
+	locker   Locker
+	opts     Options
+	handle   lockgate.LockHandle
+	holder   Holder
+	acquired bool
+	onLost   func()
+}
+
+func NewManager(locker Locker, opts Options) *Manager {
+	return &Manager{locker: locker, opts: opts}
+}
+
+// SetOnLost sets the function called when the lease of the held distributed lock is lost
+// and the lock may be taken by another run.
+func (m *Manager) SetOnLost(f func()) {
+	m.onLost = f
+}
+
+func (m *Manager) acquireOptions(opts lockgate.AcquireOptions) lockgate.AcquireOptions {
+	opts.OnLostLeaseFunc = func(lockgate.LockHandle) error {
+		if m.onLost != nil {
+			m.onLost()
+		}
+		return nil
+	}
+	return opts
+}
+
+// Acquire acquires the lock waiting up to the timeout, forever if no timeout is set and onBusy is `wait`.
+// It returns false if the lock is busy and the run should be skipped.
+func (m *Manager) Acquire(lockName string, holder Holder) (bool, error) {
+	if m.opts.Disabled {
+		acquired, handle, err := m.locker.Acquire(lockName, lockgate.AcquireOptions{NonBlocking: true})
+		if err != nil {
+			return false, err
+		}
+		m.setAcquired(handle, holder, acquired)
+		return true, nil
+	}
+
+	if m.opts.Timeout == 0 && (m.opts.OnBusy == "" || m.opts.OnBusy == OnBusyWait) {
+		if status, err := m.locker.Status(lockName); err == nil && status.Locked && status.Holder != nil {
+			log.Printf("Waiting for lock held by %s\n", status.Holder)
+		}
+		acquired, handle, err := m.locker.Acquire(lockName, m.acquireOptions(lockgate.AcquireOptions{}))
+		if err != nil {
+			return false, err
+		}
+		m.setAcquired(handle, holder, acquired)
+		return true, nil
+	}
+
+	deadline := time.Now().Add(m.opts.Timeout)
+	logged := false
+	for {
+		acquired, handle, err := m.locker.Acquire(lockName, m.acquireOptions(lockgate.AcquireOptions{NonBlocking: true}))
+		if err != nil {
+			return false, err
+		}
+		if acquired {
+			m.setAcquired(handle, holder, true)
+			return true, nil
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		if !logged {
+			log.Printf("Lock is busy, waiting up to %s\n", m.opts.Timeout)
+			logged = true
+		}
+		time.Sleep(busyPollPeriod)
+	}
+
+	busyErr := m.busyError(lockName)
+	if m.opts.OnBusy == OnBusySkip {
+		log.Printf("Skipping run: %s\n", busyErr.Error())
+		return false, nil
+	}
+	return false, busyErr
+}
+
+// SetTag records the tag being processed in the lock holder metadata.
+func (m *Manager) SetTag(tag string) {
+	if !m.acquired {
+		return
+	}
+	m.holder.Tag = tag
+	if err := m.locker.SetHolder(m.handle, m.holder); err != nil {
+		log.Printf("WARNING unable to update lock holder: %s", err.Error())
+	}
+}
+
+func (m *Manager) Release() error {
+	if !m.acquired {
+		return nil
+	}
+	m.acquired = false
+	return m.locker.Release(m.handle)
+}
+
+func (m *Manager) setAcquired(handle lockgate.LockHandle, holder Holder, acquired bool) {
+	m.handle, m.holder, m.acquired = handle, holder, acquired
+	if !acquired {
+		return
+	}
+	if err := m.locker.SetHolder(handle, holder); err != nil {
+		log.Printf("WARNING unable to record lock holder: %s", err.Error())
+	}
+}
+
+func (m *Manager) busyError(lockName string) error {
+	status, err := m.locker.Status(lockName)
+	if err != nil || status.Holder == nil {
+		return ErrBusy
+	}
+	return fmt.Errorf("%w: held by %s", ErrBusy, status.Holder)
+}
+
+// NewLocker creates the locker selected in the config, the host locker by default.
+func NewLocker(cfg *config.Lock) (Locker, error) {
+	if cfg == nil {
+		cfg = &config.Lock{}
+	}
+
+	switch cfg.Type {
+	case "", TypeLocal:
+		return NewLocalLocker()
+	case TypeKubernetes:
+		kubeCfg := cfg.Kubernetes
+		if kubeCfg == nil {
+			kubeCfg = &config.KubernetesLock{}
+		}
+		client, namespace, err := kube.NewClient(kubeCfg.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+		return NewDistributedLocker(NewKubernetesLeaseBackend(client, namespace, kubeCfg.Lease, leaseTTL(cfg))), nil
+	case TypeS3:
+		if cfg.S3 == nil {
+			return nil, fmt.Errorf("s3 lock is not configured")
+		}
+		backend, err := NewS3LockBackend(cfg.S3, leaseTTL(cfg))
+		if err != nil {
+			return nil, err
+		}
+		return NewDistributedLocker(backend), nil
+	default:
+		return nil, fmt.Errorf("unknown lock type %q", cfg.Type)
+	}
 }
 
-func NewManager(locker Locker) *Manager {
-	return &Manager{locker: locker}
+func leaseTTL(cfg *config.Lock) time.Duration {
+	if cfg.LeaseTTL == 0 {
+		return DefaultLeaseTTL
+	}
+	return cfg.LeaseTTL
 }
 
-func (m *Manager) Acquire(lockName string) error {
-	_, _, err := m.locker.Acquire(lockName, lockgate.AcquireOptions{})
-	return err
+// NewOptions returns the manager options from the config.
+func NewOptions(cfg *config.Lock, disabled bool) Options {
+	opts := Options{Disabled: disabled}
+	if cfg != nil {
+		opts.Timeout, opts.OnBusy = cfg.Timeout, cfg.OnBusy
+	}
+	return opts
 }
@@ -0,0 +1,78 @@
+package lock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/werf/lockgate"
+)
+
+// busyLocker is a locker that is always held by another run.
+type busyLocker struct {
+	holder   Holder
+	attempts int
+}
+
+func (l *busyLocker) Acquire(lockName string, opts lockgate.AcquireOptions) (bool, lockgate.LockHandle, error) {
+	l.attempts++
+	return false, lockgate.LockHandle{}, nil
+}
+
+func (l *busyLocker) Release(lock lockgate.LockHandle) error { return nil }
+
+func (l *busyLocker) SetHolder(lock lockgate.LockHandle, holder Holder) error { return nil }
+
+func (l *busyLocker) Status(lockName string) (*Status, error) {
+	return &Status{Locked: true, Holder: &l.holder}, nil
+}
+
+func (l *busyLocker) Break(lockName string) error { return nil }
+
+func TestManager_Acquire_Busy(t *testing.T) {
+	holder := Holder{PID: 42, Host: "runner-1", Tag: "v1.0.0", StartedAt: time.Now()}
+
+	acquired, err := NewManager(&busyLocker{holder: holder}, Options{OnBusy: OnBusySkip}).Acquire("repo", NewHolder(""))
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	_, err = NewManager(&busyLocker{holder: holder}, Options{OnBusy: OnBusyFail}).Acquire("repo", NewHolder(""))
+	assert.ErrorIs(t, err, ErrBusy)
+	assert.ErrorContains(t, err, "pid 42 on host runner-1")
+
+	locker := &busyLocker{holder: holder}
+	_, err = NewManager(locker, Options{Timeout: 1500 * time.Millisecond}).Acquire("repo", NewHolder(""))
+	assert.ErrorIs(t, err, ErrBusy)
+	assert.Greater(t, locker.attempts, 1)
+
+	acquired, err = NewManager(&busyLocker{holder: holder}, Options{Disabled: true}).Acquire("repo", NewHolder(""))
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+// leaseLocker is a locker that records the acquire options to simulate the lease loss.
+type leaseLocker struct {
+	busyLocker
+	opts lockgate.AcquireOptions
+}
+
+func (l *leaseLocker) Acquire(lockName string, opts lockgate.AcquireOptions) (bool, lockgate.LockHandle, error) {
+	l.opts = opts
+	return true, lockgate.LockHandle{LockName: lockName}, nil
+}
+
+func TestManager_OnLost(t *testing.T) {
+	locker := &leaseLocker{}
+	m := NewManager(locker, Options{})
+	lost := false
+	m.SetOnLost(func() { lost = true })
+
+	acquired, err := m.Acquire("repo", NewHolder(""))
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.NotNil(t, locker.opts.OnLostLeaseFunc)
+
+	require.NoError(t, locker.opts.OnLostLeaseFunc(lockgate.LockHandle{LockName: "repo"}))
+	assert.True(t, lost)
+}
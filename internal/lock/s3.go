@@ -0,0 +1,200 @@
+package lock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+
+	"trx/internal/config"
+	"trx/internal/git"
+	"trx/internal/s3"
+)
+
+const (
+	objectLock       = "lock.json"
+	maxChangeRetries = 3
+)
+
+// S3LockBackend is a distributed locker backend that holds locks with objects in an S3-compatible storage.
+// Objects are changed with conditional writes, and a lock expires if its lease is not renewed within the TTL.
+type S3LockBackend struct {
+	client *minio.Client
+	bucket string
+	prefix string
+	ttl    time.Duration
+}
+
+type s3Lock struct {
+	ID        string    `json:"id"`
+	Holder    *Holder   `json:"holder,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func NewS3LockBackend(cfg *config.S3, ttl time.Duration) (*S3LockBackend, error) {
+	client, err := s3.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &S3LockBackend{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: strings.Trim(cfg.Prefix, "/"),
+		ttl:    ttl,
+	}, nil
+}
+
+func (b *S3LockBackend) Acquire(lockName string, _ distributed_locker.AcquireOptions) (lockgate.LockHandle, error) {
+	key := b.key(lockName)
+	current, etag, err := b.read(key)
+	if err != nil {
+		return lockgate.LockHandle{}, err
+	}
+	if current != nil && current.ID != "" && time.Now().Before(current.ExpiresAt) {
+		return lockgate.LockHandle{}, distributed_locker.ErrShouldWait
+	}
+
+	handle := lockgate.LockHandle{UUID: uuid.New().String(), LockName: lockName}
+	err = b.write(key, &s3Lock{ID: handle.UUID, ExpiresAt: b.leaseExpiration()}, etag)
+	if s3.IsPreconditionFailed(err) {
+		return lockgate.LockHandle{}, distributed_locker.ErrShouldWait
+	}
+	if err != nil {
+		return lockgate.LockHandle{}, err
+	}
+	return handle, nil
+}
+
+func (b *S3LockBackend) RenewLease(handle lockgate.LockHandle) error {
+	return b.change(handle, func(lock *s3Lock) {
+		lock.ExpiresAt = b.leaseExpiration()
+	})
+}
+
+func (b *S3LockBackend) Release(handle lockgate.LockHandle) error {
+	return b.change(handle, func(lock *s3Lock) {
+		*lock = s3Lock{}
+	})
+}
+
+func (b *S3LockBackend) SetHolder(handle lockgate.LockHandle, holder Holder) error {
+	return b.change(handle, func(lock *s3Lock) {
+		lock.Holder = &holder
+	})
+}
+
+func (b *S3LockBackend) Status(lockName string) (*Status, error) {
+	current, _, err := b.read(b.key(lockName))
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.ID == "" {
+		return &Status{}, nil
+	}
+	return &Status{
+		Locked:    true,
+		Stale:     !time.Now().Before(current.ExpiresAt),
+		Holder:    current.Holder,
+		ExpiresAt: current.ExpiresAt,
+	}, nil
+}
+
+func (b *S3LockBackend) Break(lockName string) error {
+	key := b.key(lockName)
+	current, etag, err := b.read(key)
+	if err != nil || current == nil {
+		return err
+	}
+	return b.write(key, &s3Lock{}, etag)
+}
+
+// change updates the lock held with the handle, retrying if the lock object is changed concurrently by the same holder.
+func (b *S3LockBackend) change(handle lockgate.LockHandle, update func(lock *s3Lock)) error {
+	key := b.key(handle.LockName)
+	for i := 0; ; i++ {
+		current, etag, err := b.read(key)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case current == nil || current.ID == "":
+			return distributed_locker.ErrNoExistingLockLeaseFound
+		case current.ID != handle.UUID:
+			return distributed_locker.ErrLockAlreadyLeased
+		}
+
+		update(current)
+		err = b.write(key, current, etag)
+		if s3.IsPreconditionFailed(err) {
+			if i < maxChangeRetries {
+				continue
+			}
+			return distributed_locker.ErrLockAlreadyLeased
+		}
+		return err
+	}
+}
+
+func (b *S3LockBackend) key(lockName string) string {
+	return path.Join(b.prefix, git.RepoKeyFromUrl(lockName), objectLock)
+}
+
+// read returns the lock with the object ETag, or nil if the lock object does not exist.
+func (b *S3LockBackend) read(key string) (*s3Lock, string, error) {
+	obj, err := b.client.GetObject(context.Background(), b.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("error read lock %s: %w", key, err)
+	}
+	defer obj.Close()
+
+	info, err := obj.Stat()
+	if s3.IsNotFound(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("error read lock %s: %w", key, err)
+	}
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, "", fmt.Errorf("error read lock %s: %w", key, err)
+	}
+
+	lock := &s3Lock{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, "", fmt.Errorf("error parse lock %s: %w", key, err)
+	}
+	return lock, info.ETag, nil
+}
+
+// write writes the lock if the object ETag matches the given one, or if the object does not exist when the ETag is empty.
+func (b *S3LockBackend) write(key string, lock *s3Lock, etag string) error {
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("unable to marshal lock: %w", err)
+	}
+
+	opts := minio.PutObjectOptions{ContentType: "application/json"}
+	if etag == "" {
+		opts.SetMatchETagExcept("*")
+	} else {
+		opts.SetMatchETag(etag)
+	}
+	if _, err := b.client.PutObject(context.Background(), b.bucket, key, bytes.NewReader(data), int64(len(data)), opts); err != nil {
+		return fmt.Errorf("error write lock %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *S3LockBackend) leaseExpiration() time.Time {
+	return time.Now().Add(b.ttl)
+}
@@ -0,0 +1,74 @@
+package lock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+
+	"trx/internal/s3/s3test"
+)
+
+const testRepoUrl = "git@github.com:flant/trx.git"
+
+func TestS3LockBackend(t *testing.T) {
+	cfg := s3test.NewServer(t)
+	first, err := NewS3LockBackend(cfg, DefaultLeaseTTL)
+	require.NoError(t, err)
+	second, err := NewS3LockBackend(cfg, DefaultLeaseTTL)
+	require.NoError(t, err)
+
+	handle, err := first.Acquire(testRepoUrl, distributed_locker.AcquireOptions{})
+	require.NoError(t, err)
+
+	_, err = second.Acquire(testRepoUrl, distributed_locker.AcquireOptions{})
+	assert.ErrorIs(t, err, distributed_locker.ErrShouldWait)
+
+	require.NoError(t, first.SetHolder(handle, Holder{PID: 42, Host: "runner-1", Tag: "v1.0.0"}))
+	require.NoError(t, first.RenewLease(handle))
+
+	status, err := second.Status(testRepoUrl)
+	require.NoError(t, err)
+	assert.True(t, status.Locked)
+	assert.False(t, status.Stale)
+	require.NotNil(t, status.Holder)
+	assert.Equal(t, "v1.0.0", status.Holder.Tag)
+
+	require.NoError(t, first.Release(handle))
+	assert.ErrorIs(t, first.RenewLease(handle), distributed_locker.ErrNoExistingLockLeaseFound)
+
+	otherHandle, err := second.Acquire(testRepoUrl, distributed_locker.AcquireOptions{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, first.Release(handle), distributed_locker.ErrLockAlreadyLeased)
+	require.NoError(t, second.Release(otherHandle))
+}
+
+func TestS3LockBackend_ExpiredLease(t *testing.T) {
+	cfg := s3test.NewServer(t)
+	first, err := NewS3LockBackend(cfg, DefaultLeaseTTL)
+	require.NoError(t, err)
+	second, err := NewS3LockBackend(cfg, DefaultLeaseTTL)
+	require.NoError(t, err)
+
+	handle, err := first.Acquire(testRepoUrl, distributed_locker.AcquireOptions{})
+	require.NoError(t, err)
+
+	key := first.key(testRepoUrl)
+	_, etag, err := first.read(key)
+	require.NoError(t, err)
+	require.NoError(t, first.write(key, &s3Lock{ID: handle.UUID}, etag))
+
+	status, err := second.Status(testRepoUrl)
+	require.NoError(t, err)
+	assert.True(t, status.Stale)
+
+	_, err = second.Acquire(testRepoUrl, distributed_locker.AcquireOptions{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, first.RenewLease(handle), distributed_locker.ErrLockAlreadyLeased)
+
+	require.NoError(t, first.Break(testRepoUrl))
+	status, err = second.Status(testRepoUrl)
+	require.NoError(t, err)
+	assert.False(t, status.Locked)
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/go-git/go-git/v5"
 	"golang.org/x/sync/errgroup"
 
@@ -25,30 +26,147 @@ func (e *Error) Unwrap() error {
 	return e.Err
 }
 
-func CheckQuorums(quorums []config.Quorum, repo *git.Repository, tag string) error {
+type Result struct {
+	QuorumName      string
+	MinNumberOfKeys int
+	Signers         []string
+	Err             error
+}
+
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+const (
+	BumpMajor = "major"
+	BumpMinor = "minor"
+	BumpPatch = "patch"
+)
+
+// CheckQuorums verifies the tag against every quorum applying to it and returns per-quorum results.
+// The previous tag is the last processed one, it selects the quorums by bump type.
+// The returned error is the *Error of the first failed quorum in config order.
+func CheckQuorums(quorums []config.Quorum, repo *git.Repository, tag, previousTag string) ([]Result, error) {
+	var selected []int
+	for i, q := range quorums {
+		ok, err := applies(q, tag, previousTag)
+		if err != nil {
+			return nil, &Error{QuorumName: quorumName(i, q), Err: err}
+		}
+		if !ok {
+			log.Printf("Quorum %s does not apply to tag %s, skipping\n", quorumName(i, q), tag)
+			continue
+		}
+		selected = append(selected, i)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no quorum applies to tag %s", tag)
+	}
+
+	results := make([]Result, len(selected))
 	var g errgroup.Group
-	for _, q := range quorums {
+	for j, i := range selected {
 		g.Go(func() error {
-			log.Printf("Verifying quorum %s\n", *q.Name)
-			keys, err := parseGPGKeys(q.GPGKeys, q.GPGKeyFilesPaths)
-			if err != nil {
-				return &Error{QuorumName: *q.Name, Err: fmt.Errorf("quorum `%s` error reading GPG keys: %w", *q.Name, err)}
-			}
-			err = trdlGit.VerifyTagSignatures(repo, trdlGit.VerifyTagSignaturesRequest{
-				Tag:          tag,
-				NumberOfKeys: q.MinNumberOfKeys,
-				GPGKeys:      keys,
-			})
-			if err != nil {
-				return &Error{QuorumName: *q.Name, Err: err}
-			}
+			results[j] = checkQuorum(quorumName(i, quorums[i]), quorums[i], repo, tag)
 			return nil
 		})
 	}
-	if err := g.Wait(); err != nil {
-		return err
+	_ = g.Wait()
+
+	for _, r := range results {
+		if r.Err != nil {
+			return results, &Error{QuorumName: r.QuorumName, Err: r.Err}
+		}
+	}
+	return results, nil
+}
+
+// applies reports whether the quorum is required for the tag. A quorum with both versions and bump
+// set applies only if the tag matches both.
+func applies(q config.Quorum, tag, previousTag string) (bool, error) {
+	if q.Versions == "" && q.Bump == "" {
+		return true, nil
+	}
+	ver, err := semver.NewVersion(tag)
+	if err != nil {
+		return false, fmt.Errorf("invalid tag %s: %w", tag, err)
+	}
+	if q.Versions != "" {
+		constraint, err := semver.NewConstraint(q.Versions)
+		if err != nil {
+			return false, fmt.Errorf("invalid versions constraint %q: %w", q.Versions, err)
+		}
+		// Constraints never match prereleases, so the release version is checked to keep a prerelease tag
+		// from escaping the quorums of its version range.
+		release, err := ver.SetPrerelease("")
+		if err != nil {
+			return false, fmt.Errorf("invalid tag %s: %w", tag, err)
+		}
+		if !constraint.Check(&release) {
+			return false, nil
+		}
+	}
+	if q.Bump != "" {
+		b, err := bump(ver, previousTag)
+		if err != nil {
+			return false, err
+		}
+		if b != q.Bump {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// bump returns the most significant part of the version changed since the previous tag.
+// The first tag has no previous one and is treated as a major bump.
+func bump(ver *semver.Version, previousTag string) (string, error) {
+	if previousTag == "" {
+		return BumpMajor, nil
+	}
+	prev, err := semver.NewVersion(previousTag)
+	if err != nil {
+		return "", fmt.Errorf("invalid previous tag %s: %w", previousTag, err)
+	}
+	switch {
+	case ver.Major() != prev.Major():
+		return BumpMajor, nil
+	case ver.Minor() != prev.Minor():
+		return BumpMinor, nil
+	default:
+		return BumpPatch, nil
+	}
+}
+
+func checkQuorum(name string, q config.Quorum, repo *git.Repository, tag string) Result {
+	res := Result{QuorumName: name, MinNumberOfKeys: q.MinNumberOfKeys}
+
+	log.Printf("Verifying quorum %s\n", name)
+	keys, err := parseGPGKeys(q.GPGKeys, q.GPGKeyFilesPaths)
+	if err != nil {
+		res.Err = fmt.Errorf("quorum `%s` error reading GPG keys: %w", name, err)
+		return res
+	}
+
+	res.Signers, err = trdlGit.TagSigners(repo, tag, keys)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	res.Err = trdlGit.VerifyTagSignatures(repo, trdlGit.VerifyTagSignaturesRequest{
+		Tag:          tag,
+		NumberOfKeys: q.MinNumberOfKeys,
+		GPGKeys:      keys,
+	})
+	return res
+}
+
+func quorumName(i int, q config.Quorum) string {
+	if q.Name != nil {
+		return *q.Name
 	}
-	return nil
+	return fmt.Sprintf("#%d", i+1)
 }
 
 func parseGPGKeys(plain, files []string) ([]string, error) {
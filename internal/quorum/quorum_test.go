@@ -0,0 +1,59 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trx/internal/config"
+)
+
+func TestApplies(t *testing.T) {
+	tcs := []struct {
+		name        string
+		quorum      config.Quorum
+		tag         string
+		previousTag string
+		expected    bool
+	}{
+		{name: "no selectors", quorum: config.Quorum{}, tag: "not-semver", expected: true},
+		{name: "versions match", quorum: config.Quorum{Versions: ">=2.0.0"}, tag: "v2.1.0", expected: true},
+		{name: "versions mismatch", quorum: config.Quorum{Versions: ">=2.0.0"}, tag: "v1.9.0", expected: false},
+		{name: "prerelease matches versions", quorum: config.Quorum{Versions: ">=2.0.0"}, tag: "v2.0.1-rc.1", previousTag: "v2.0.0", expected: true},
+		{name: "prerelease of range start matches versions", quorum: config.Quorum{Versions: ">=2.0.0"}, tag: "v2.0.0-rc.1", expected: true},
+		{name: "prerelease mismatch", quorum: config.Quorum{Versions: ">=2.0.0"}, tag: "v1.9.1-rc.1", expected: false},
+		{name: "prerelease patch bump", quorum: config.Quorum{Bump: BumpPatch}, tag: "v2.0.1-rc.1", previousTag: "v2.0.0", expected: true},
+		{name: "major bump", quorum: config.Quorum{Bump: BumpMajor}, tag: "v2.0.0", previousTag: "v1.9.3", expected: true},
+		{name: "minor bump", quorum: config.Quorum{Bump: BumpMinor}, tag: "v1.10.0", previousTag: "v1.9.3", expected: true},
+		{name: "patch bump", quorum: config.Quorum{Bump: BumpPatch}, tag: "v1.9.4", previousTag: "v1.9.3", expected: true},
+		{name: "patch rule on minor bump", quorum: config.Quorum{Bump: BumpPatch}, tag: "v1.10.0", previousTag: "v1.9.3", expected: false},
+		{name: "rollback across major", quorum: config.Quorum{Bump: BumpMajor}, tag: "v1.9.3", previousTag: "v2.0.0", expected: true},
+		{name: "first tag is major", quorum: config.Quorum{Bump: BumpMajor}, tag: "v0.1.0", expected: true},
+		{name: "versions and bump", quorum: config.Quorum{Versions: ">=2.0.0", Bump: BumpPatch}, tag: "v1.9.4", previousTag: "v1.9.3", expected: false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := applies(tc.quorum, tc.tag, tc.previousTag)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, ok)
+		})
+	}
+}
+
+func TestApplies_invalidTag(t *testing.T) {
+	_, err := applies(config.Quorum{Versions: ">=2.0.0"}, "latest", "")
+	assert.Error(t, err)
+
+	_, err = applies(config.Quorum{Bump: BumpPatch}, "v1.0.1", "latest")
+	assert.Error(t, err)
+}
+
+func TestCheckQuorums_noneApplies(t *testing.T) {
+	quorums := []config.Quorum{{Versions: ">=2.0.0", MinNumberOfKeys: 1}}
+
+	results, err := CheckQuorums(quorums, nil, "v1.0.0", "")
+	assert.Empty(t, results)
+	assert.ErrorContains(t, err, "no quorum applies to tag v1.0.0")
+}
@@ -0,0 +1,210 @@
+package runlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"trx/internal/history"
+)
+
+const (
+	FileOutput   = "output.log"
+	FileCommands = "commands.json"
+	FileEnv      = "env"
+	FileSummary  = "summary.json"
+	DirSteps     = "steps"
+
+	DefaultKeepLast = 50
+
+	runIDTimeLayout = "20060102T150405Z"
+)
+
+// Run is the directory with the output and details of a run:
+// the combined output, per-step logs, resolved commands, environment with secrets redacted and the summary.
+type Run struct {
+	ID  string
+	Dir string
+
+	mu       sync.Mutex
+	output   *os.File
+	commands []Command
+}
+
+type Command struct {
+	Step    string   `json:"step"`
+	Args    []string `json:"args"`
+	WorkDir string   `json:"workdir"`
+	Env     []string `json:"env,omitempty"`
+}
+
+// BaseDir returns the directory with the runs of the repository, `~/.trx/runs/<host>/<path>` by default.
+func BaseDir(path, repoKey string) (string, error) {
+	if path == "" {
+		usr, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(usr.HomeDir, ".trx", "runs")
+	}
+	return filepath.Join(path, filepath.FromSlash(repoKey)), nil
+}
+
+func Create(baseDir, runID string) (*Run, error) {
+	dir := filepath.Join(baseDir, runID)
+	if err := os.MkdirAll(filepath.Join(dir, DirSteps), 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create run directory: %w", err)
+	}
+	output, err := os.OpenFile(filepath.Join(dir, FileOutput), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create run output file: %w", err)
+	}
+	return &Run{ID: runID, Dir: dir, output: output}, nil
+}
+
+// Write writes to the combined output.
+func (r *Run) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.output.Write(p)
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// StepLog creates the log file of the step.
+func (r *Run) StepLog(index int, name string) (*os.File, error) {
+	path := filepath.Join(r.Dir, DirSteps, fmt.Sprintf("%02d-%s.log", index+1, safeName(name)))
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create step log: %w", err)
+	}
+	return f, nil
+}
+
+// FindStepLog returns the path of the log of the named step in the run directory.
+func FindStepLog(dir, name string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, DirSteps, "[0-9][0-9]-"+safeName(name)+".log"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no log found for step %s", name)
+	}
+	return matches[0], nil
+}
+
+func safeName(name string) string {
+	return strings.Trim(unsafeNameChars.ReplaceAllString(name, "-"), "-")
+}
+
+func (r *Run) AddCommand(c Command) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, c)
+	return writeJson(filepath.Join(r.Dir, FileCommands), r.commands)
+}
+
+func (r *Run) WriteEnv(env []string) error {
+	data := strings.Join(RedactEnv(env), "\n") + "\n"
+	return os.WriteFile(filepath.Join(r.Dir, FileEnv), []byte(data), 0o600)
+}
+
+func (r *Run) WriteSummary(record history.Record) error {
+	return writeJson(filepath.Join(r.Dir, FileSummary), record)
+}
+
+func (r *Run) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.output.Close()
+}
+
+var secretKeyPattern = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|SECRET|TOKEN|CREDENTIAL|PRIVATE|_KEY$|^KEY$)`)
+
+// RedactEnv replaces the values of variables that look like secrets with `***`.
+func RedactEnv(env []string) []string {
+	redacted := make([]string, len(env))
+	for i, e := range env {
+		k, _, _ := strings.Cut(e, "=")
+		if secretKeyPattern.MatchString(k) {
+			e = k + "=***"
+		}
+		redacted[i] = e
+	}
+	return redacted
+}
+
+// List returns the IDs of the runs in chronological order.
+func List(baseDir string) ([]string, error) {
+	entries, err := os.ReadDir(baseDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read runs directory: %w", err)
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Cleanup removes the runs older than maxAge and all but the keepLast most recent runs. Zero values disable the limits.
+func Cleanup(baseDir string, keepLast int, maxAge time.Duration) error {
+	ids, err := List(baseDir)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		expired := keepLast > 0 && i < len(ids)-keepLast
+		if !expired && maxAge > 0 {
+			startedAt, err := runStartTime(filepath.Join(baseDir, id))
+			if err != nil {
+				return err
+			}
+			expired = time.Since(startedAt) > maxAge
+		}
+		if expired {
+			if err := os.RemoveAll(filepath.Join(baseDir, id)); err != nil {
+				return fmt.Errorf("unable to remove run %s: %w", id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runStartTime returns the start time from the run ID, or the directory modification time if the ID has another format.
+func runStartTime(dir string) (time.Time, error) {
+	id := filepath.Base(dir)
+	if len(id) >= len(runIDTimeLayout) {
+		if t, err := time.Parse(runIDTimeLayout, id[:len(runIDTimeLayout)]); err == nil {
+			return t, nil
+		}
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func writeJson(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,75 @@
+package runlog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRedactEnv(t *testing.T) {
+	env := []string{"PATH=/bin", "API_TOKEN=abc", "DB_PASSWORD=x=y", "SSH_KEY=k", "KEYBOARD=us"}
+	want := []string{"PATH=/bin", "API_TOKEN=***", "DB_PASSWORD=***", "SSH_KEY=***", "KEYBOARD=us"}
+	if got := RedactEnv(env); !reflect.DeepEqual(got, want) {
+		t.Fatalf("RedactEnv() = %v, want %v", got, want)
+	}
+}
+
+func TestStepLog(t *testing.T) {
+	run, err := Create(t.TempDir(), "20240101T000000Z-00000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer run.Close()
+
+	f, err := run.StepLog(1, "build image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if filepath.Base(f.Name()) != "02-build-image.log" {
+		t.Fatalf("unexpected step log name %s", f.Name())
+	}
+
+	path, err := FindStepLog(run.Dir, "build image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != f.Name() {
+		t.Fatalf("FindStepLog() = %s, want %s", path, f.Name())
+	}
+	if _, err := FindStepLog(run.Dir, "deploy"); err == nil {
+		t.Fatal("expected error for unknown step")
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC()
+	ids := []string{
+		now.Add(-72*time.Hour).Format(runIDTimeLayout) + "-00000001",
+		now.Add(-3*time.Hour).Format(runIDTimeLayout) + "-00000002",
+		now.Add(-2*time.Hour).Format(runIDTimeLayout) + "-00000003",
+		now.Add(-time.Hour).Format(runIDTimeLayout) + "-00000004",
+	}
+	for _, id := range ids {
+		if err := os.Mkdir(filepath.Join(dir, id), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Cleanup(dir, 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := List(dir); !reflect.DeepEqual(got, ids[1:]) {
+		t.Fatalf("after keepLast cleanup runs = %v, want %v", got, ids[1:])
+	}
+
+	if err := Cleanup(dir, 0, 150*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := List(dir); !reflect.DeepEqual(got, ids[2:]) {
+		t.Fatalf("after maxAge cleanup runs = %v, want %v", got, ids[2:])
+	}
+}
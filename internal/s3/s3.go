@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"trx/internal/config"
+)
+
+const defaultEndpoint = "s3.amazonaws.com"
+
+// NewClient creates a client for any S3-compatible object storage.
+// Static credentials are used if specified, otherwise they are taken from the environment, AWS credentials file or IAM.
+func NewClient(cfg *config.S3) (*minio.Client, error) {
+	endpoint := cfg.Endpoint
+	secure := !cfg.Insecure
+	switch {
+	case endpoint == "":
+		endpoint = defaultEndpoint
+	case strings.HasPrefix(endpoint, "http://"):
+		endpoint, secure = strings.TrimPrefix(endpoint, "http://"), false
+	case strings.HasPrefix(endpoint, "https://"):
+		endpoint, secure = strings.TrimPrefix(endpoint, "https://"), true
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKeyID != "" {
+		creds = credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken)
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		})
+	}
+
+	bucketLookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(strings.TrimSuffix(endpoint, "/"), &minio.Options{
+		Creds:        creds,
+		Secure:       secure,
+		Region:       cfg.Region,
+		BucketLookup: bucketLookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create s3 client: %w", err)
+	}
+	return client, nil
+}
+
+func IsNotFound(err error) bool {
+	resp := toErrorResponse(err)
+	return resp.Code == "NoSuchKey" || resp.StatusCode == http.StatusNotFound
+}
+
+// IsPreconditionFailed reports whether a conditional write failed because the object was changed.
+func IsPreconditionFailed(err error) bool {
+	resp := toErrorResponse(err)
+	return resp.Code == "PreconditionFailed" || resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict
+}
+
+func toErrorResponse(err error) minio.ErrorResponse {
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) {
+		return resp
+	}
+	return minio.ErrorResponse{}
+}
@@ -0,0 +1,161 @@
+package s3test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"trx/internal/config"
+)
+
+// server is a minimal S3-compatible server supporting path-style object reads, conditional writes and listing.
+type server struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewServer starts a fake S3 server for the test and returns the config to connect to it.
+func NewServer(t *testing.T) *config.S3 {
+	f := &server{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	return &config.S3{
+		Bucket:          "trx",
+		Prefix:          "state",
+		Endpoint:        srv.URL,
+		Region:          "us-east-1",
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}
+}
+
+func (f *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if bucket, name, _ := strings.Cut(key, "/"); name == "" {
+		f.list(w, bucket, r.URL.Query().Get("prefix"))
+		return
+	}
+
+	data, exists := f.objects[key]
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"`+etag(data)+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case http.MethodPut:
+		ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+		if (ifNoneMatch == "*" && exists) || (ifMatch != "" && (!exists || ifMatch != `"`+etag(data)+`"`)) {
+			writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequest")
+			return
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", `"`+etag(body)+`"`)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *server) list(w http.ResponseWriter, bucket, prefix string) {
+	type content struct {
+		Key          string
+		ETag         string
+		Size         int
+		LastModified string
+	}
+	type result struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}
+
+	res := result{Name: bucket, Prefix: prefix, MaxKeys: 1000}
+	for key, data := range f.objects {
+		name := strings.TrimPrefix(key, bucket+"/")
+		if strings.HasPrefix(name, prefix) {
+			res.Contents = append(res.Contents, content{
+				Key:          name,
+				ETag:         `"` + etag(data) + `"`,
+				Size:         len(data),
+				LastModified: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+			})
+		}
+	}
+	sort.Slice(res.Contents, func(i, j int) bool { return res.Contents[i].Key < res.Contents[j].Key })
+	res.KeyCount = len(res.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(res)
+}
+
+// readBody reads the request body decoding the aws-chunked encoding used for streaming signatures.
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var body bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseInt(strings.SplitN(strings.TrimSpace(line), ";", 2)[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body.Bytes(), nil
+		}
+		if _, err := io.CopyN(&body, br, size); err != nil {
+			return nil, err
+		}
+		if _, err := br.ReadString('\n'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
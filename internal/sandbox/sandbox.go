@@ -0,0 +1,80 @@
+package sandbox
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"trx/internal/config"
+)
+
+// InitArg is argv[0] of trx re-executed as the sandbox init process,
+// which sets up the namespaces, limits and credentials and then executes the command.
+const InitArg = "trx-sandbox-init"
+
+// initExitCode is the exit code of the init process if the sandbox can't be set up.
+const initExitCode = 126
+
+// Spec describes the sandbox of a command.
+type Spec struct {
+	UID            *int     `json:"uid,omitempty"`
+	GID            *int     `json:"gid,omitempty"`
+	ReadOnlyPaths  []string `json:"readOnlyPaths,omitempty"`
+	PrivateTmp     bool     `json:"privateTmp,omitempty"`
+	IsolateNetwork bool     `json:"isolateNetwork,omitempty"`
+	Limits         Limits   `json:"limits"`
+}
+
+// Limits are the rlimits of the command, zero values are not set.
+type Limits struct {
+	CPUSeconds  uint64 `json:"cpuSeconds,omitempty"`
+	MemoryBytes uint64 `json:"memoryBytes,omitempty"`
+	OpenFiles   uint64 `json:"openFiles,omitempty"`
+}
+
+// NewSpec returns the sandbox spec from the config, or nil if the sandbox is not configured.
+func NewSpec(cfg *config.Sandbox, checkout string) (*Spec, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	spec := &Spec{
+		UID:            cfg.UID,
+		GID:            cfg.GID,
+		PrivateTmp:     cfg.PrivateTmp,
+		IsolateNetwork: cfg.IsolateNetwork,
+	}
+	if cfg.ReadOnlyCheckout {
+		spec.ReadOnlyPaths = []string{checkout}
+	}
+	if rel, err := filepath.Rel("/tmp", checkout); cfg.PrivateTmp && err == nil && filepath.IsLocal(rel) {
+		return nil, fmt.Errorf("checkout %s is inside /tmp and is hidden by the private /tmp", checkout)
+	}
+	if l := cfg.Limits; l != nil {
+		memory, err := l.MemoryBytes()
+		if err != nil {
+			return nil, err
+		}
+		spec.Limits = Limits{
+			CPUSeconds:  uint64(math.Ceil(l.CPU.Seconds())),
+			MemoryBytes: memory,
+			OpenFiles:   l.OpenFiles,
+		}
+	}
+	return spec, nil
+}
+
+func (s *Spec) needsMountNamespace() bool {
+	return len(s.ReadOnlyPaths) > 0 || s.PrivateTmp
+}
+
+// IsInit reports whether the process is the sandbox init process.
+func IsInit() bool {
+	return len(os.Args) > 0 && os.Args[0] == InitArg
+}
+
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "sandbox error: %s\n", err.Error())
+	os.Exit(initExitCode)
+}
@@ -0,0 +1,195 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Wrap makes the command start the sandbox init process in new namespaces, which then executes the original command.
+// If trx is not run as root, an unprivileged user namespace is used to set up the mounts and the network namespace.
+func (s *Spec) Wrap(cmd *exec.Cmd) error {
+	if s == nil || cmd.Err != nil {
+		return nil
+	}
+
+	spec, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("unable to marshal sandbox spec: %w", err)
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	if s.needsMountNamespace() {
+		attr.Cloneflags |= syscall.CLONE_NEWNS
+	}
+	if s.IsolateNetwork {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	if attr.Cloneflags != 0 && os.Geteuid() != 0 {
+		if s.UID != nil || s.GID != nil {
+			return fmt.Errorf("running commands as another user requires trx to run as root")
+		}
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Geteuid(), HostID: os.Geteuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getegid(), HostID: os.Getegid(), Size: 1}}
+		attr.GidMappingsEnableSetgroups = false
+	}
+
+	cmd.Args = append([]string{InitArg, string(spec), cmd.Path}, cmd.Args...)
+	cmd.Path = "/proc/self/exe"
+	return nil
+}
+
+// Init sets up the sandbox and executes the command. It is run in the re-executed trx process and never returns.
+// The arguments are the spec, the command path and the command argv.
+func Init() {
+	if len(os.Args) < 4 {
+		exitWithError(fmt.Errorf("invalid sandbox init arguments"))
+	}
+	spec := &Spec{}
+	if err := json.Unmarshal([]byte(os.Args[1]), spec); err != nil {
+		exitWithError(fmt.Errorf("unable to parse sandbox spec: %w", err))
+	}
+	if err := spec.setup(); err != nil {
+		exitWithError(err)
+	}
+	if err := syscall.Exec(os.Args[2], os.Args[3:], os.Environ()); err != nil {
+		exitWithError(fmt.Errorf("unable to execute %s: %w", os.Args[2], err))
+	}
+}
+
+func (s *Spec) setup() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	if s.needsMountNamespace() {
+		if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+			return fmt.Errorf("unable to make mounts private: %w", err)
+		}
+		for _, path := range s.ReadOnlyPaths {
+			if err := bindReadOnly(path); err != nil {
+				return err
+			}
+		}
+		if s.PrivateTmp {
+			if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+				return fmt.Errorf("unable to mount private /tmp: %w", err)
+			}
+		}
+	}
+
+	if s.IsolateNetwork {
+		if err := loopbackUp(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.Limits.set(); err != nil {
+		return err
+	}
+
+	if s.UID != nil || s.GID != nil {
+		if err := syscall.Setgroups(nil); err != nil {
+			return fmt.Errorf("unable to drop supplementary groups: %w", err)
+		}
+	}
+	if s.GID != nil {
+		if err := syscall.Setgid(*s.GID); err != nil {
+			return fmt.Errorf("unable to set gid: %w", err)
+		}
+	}
+	if s.UID != nil {
+		if err := syscall.Setuid(*s.UID); err != nil {
+			return fmt.Errorf("unable to set uid: %w", err)
+		}
+	}
+
+	// The working directory may be under a path mounted over, so it is entered again.
+	if err := os.Chdir(wd); err != nil {
+		return fmt.Errorf("unable to enter working directory: %w", err)
+	}
+	return nil
+}
+
+// bindReadOnly mounts the path over itself read-only.
+// The flags of the existing mount are kept, since a user namespace may not clear them.
+func bindReadOnly(path string) error {
+	if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("unable to bind %s: %w", path, err)
+	}
+
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return fmt.Errorf("unable to stat %s: %w", path, err)
+	}
+	flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+	for stFlag, msFlag := range map[int64]uintptr{
+		unix.ST_NOSUID:     unix.MS_NOSUID,
+		unix.ST_NODEV:      unix.MS_NODEV,
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if int64(st.Flags)&stFlag != 0 {
+			flags |= msFlag
+		}
+	}
+	if err := unix.Mount("", path, "", flags, ""); err != nil {
+		return fmt.Errorf("unable to make %s read-only: %w", path, err)
+	}
+	return nil
+}
+
+// loopbackUp brings up the loopback interface of the new network namespace.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("unable to get loopback flags: %w", err)
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("unable to bring loopback up: %w", err)
+	}
+	return nil
+}
+
+func (l Limits) set() error {
+	for _, rl := range []struct {
+		name     string
+		resource int
+		limit    uint64
+	}{
+		{"cpu", unix.RLIMIT_CPU, l.CPUSeconds},
+		{"memory", unix.RLIMIT_AS, l.MemoryBytes},
+		{"open files", unix.RLIMIT_NOFILE, l.OpenFiles},
+	} {
+		if rl.limit == 0 {
+			continue
+		}
+		if err := unix.Setrlimit(rl.resource, &unix.Rlimit{Cur: rl.limit, Max: rl.limit}); err != nil {
+			return fmt.Errorf("unable to set %s limit: %w", rl.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	if IsInit() {
+		Init()
+	}
+	os.Exit(m.Run())
+}
+
+// runSandboxed runs the script in the sandbox and returns its output, skipping the test if namespaces are not available.
+func runSandboxed(t *testing.T, spec *Spec, dir, script string) (string, error) {
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	require.NoError(t, spec.Wrap(cmd))
+
+	err := cmd.Run()
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EINVAL) {
+		t.Skipf("namespaces are not available: %s", err)
+	}
+	return strings.TrimSpace(out.String()), err
+}
+
+// outsideTmpDir creates a directory outside of /tmp hidden by the private /tmp, skipping the test if there is no such place.
+func outsideTmpDir(t *testing.T) string {
+	var bases []string
+	if !underTmp(os.TempDir()) {
+		bases = append(bases, os.TempDir())
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		bases = append(bases, dir)
+	}
+	for _, base := range bases {
+		if err := os.MkdirAll(base, 0o755); err != nil {
+			continue
+		}
+		dir, err := os.MkdirTemp(base, "trx-sandbox-test")
+		if err != nil {
+			continue
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		if dir, err = filepath.EvalSymlinks(dir); err == nil && !underTmp(dir) {
+			return dir
+		}
+	}
+	t.Skip("no writable directory outside of /tmp")
+	return ""
+}
+
+func underTmp(path string) bool {
+	rel, err := filepath.Rel("/tmp", path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+func TestSandbox_ReadOnlyCheckoutAndPrivateTmp(t *testing.T) {
+	checkout := outsideTmpDir(t)
+	require.NoError(t, os.WriteFile(filepath.Join(checkout, "file"), []byte("data"), 0o644))
+	marker, err := os.CreateTemp("/tmp", "trx-sandbox-test")
+	require.NoError(t, err)
+	marker.Close()
+	defer os.Remove(marker.Name())
+
+	spec := &Spec{ReadOnlyPaths: []string{checkout}, PrivateTmp: true}
+
+	out, err := runSandboxed(t, spec, checkout, "cat file && ! touch new 2>/dev/null && ls -A /tmp && touch /tmp/scratch")
+	require.NoError(t, err, out)
+	assert.Equal(t, "data", out)
+	assert.NoFileExists(t, filepath.Join(checkout, "new"))
+	assert.NoFileExists(t, "/tmp/scratch")
+}
+
+func TestSandbox_IsolateNetworkAndLimits(t *testing.T) {
+	spec := &Spec{IsolateNetwork: true, Limits: Limits{OpenFiles: 64, CPUSeconds: 10}}
+
+	out, err := runSandboxed(t, spec, t.TempDir(), "ulimit -n; ulimit -t; tail -n +3 /proc/net/dev | cut -d: -f1 | tr -d ' '")
+	require.NoError(t, err, out)
+	assert.Equal(t, []string{"64", "10", "lo"}, strings.Split(out, "\n"))
+}
+
+func TestSandbox_InitError(t *testing.T) {
+	spec := &Spec{ReadOnlyPaths: []string{"/nonexistent/trx"}}
+
+	out, err := runSandboxed(t, spec, t.TempDir(), "true")
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, initExitCode, exitErr.ExitCode())
+	assert.Contains(t, out, "sandbox error: unable to bind /nonexistent/trx")
+}
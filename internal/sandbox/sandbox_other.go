@@ -0,0 +1,20 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Wrap fails, the sandbox is only supported on Linux.
+func (s *Spec) Wrap(cmd *exec.Cmd) error {
+	if s == nil {
+		return nil
+	}
+	return fmt.Errorf("sandbox is only supported on Linux")
+}
+
+func Init() {
+	exitWithError(fmt.Errorf("sandbox is only supported on Linux"))
+}
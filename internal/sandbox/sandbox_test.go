@@ -0,0 +1,40 @@
+package sandbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trx/internal/config"
+)
+
+func TestNewSpec(t *testing.T) {
+	spec, err := NewSpec(nil, "/srv/repo")
+	require.NoError(t, err)
+	assert.Nil(t, spec)
+
+	uid, gid := 1000, 1000
+	spec, err = NewSpec(&config.Sandbox{
+		UID:              &uid,
+		GID:              &gid,
+		ReadOnlyCheckout: true,
+		PrivateTmp:       true,
+		Limits:           &config.SandboxLimits{CPU: 1500 * time.Millisecond, Memory: "1Gi", OpenFiles: 1024},
+	}, "/srv/repo")
+	require.NoError(t, err)
+	assert.Equal(t, &Spec{
+		UID:           &uid,
+		GID:           &gid,
+		ReadOnlyPaths: []string{"/srv/repo"},
+		PrivateTmp:    true,
+		Limits:        Limits{CPUSeconds: 2, MemoryBytes: 1 << 30, OpenFiles: 1024},
+	}, spec)
+
+	_, err = NewSpec(&config.Sandbox{PrivateTmp: true}, "/tmp/repo")
+	assert.ErrorContains(t, err, "hidden by the private /tmp")
+
+	_, err = NewSpec(&config.Sandbox{Limits: &config.SandboxLimits{Memory: "lots"}}, "/srv/repo")
+	assert.Error(t, err)
+}
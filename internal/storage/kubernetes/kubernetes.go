@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	"trx/internal/history"
+)
+
+const TypeKubernetesStorage = "kubernetes"
+
+const (
+	keyLastProcessedTag = "lastProcessedTag"
+	keyTagCommits       = "tagCommits"
+	keyRecord           = "record"
+
+	labelManagedBy = "app.kubernetes.io/managed-by"
+	labelState     = "trx.flant.com/state"
+	labelHistory   = "trx.flant.com/history"
+)
+
+var ErrConcurrentUpdate = errors.New("storage state was modified by another run")
+
+// Kubernetes keeps the state in a ConfigMap and every history record in a separate ConfigMap labeled with the state name.
+type Kubernetes struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+
+	mu              sync.Mutex
+	stateRead       bool
+	resourceVersion string
+}
+
+func NewKubernetesStorage(client kubernetes.Interface, namespace, name string) *Kubernetes {
+	return &Kubernetes{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+func (s *Kubernetes) CheckLastSucceedTag() (string, error) {
+	cm, _, err := s.getState()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.stateRead, s.resourceVersion = true, cm.ResourceVersion
+	s.mu.Unlock()
+
+	return cm.Data[keyLastProcessedTag], nil
+}
+
+// StoreSucceedTag stores the tag only if the state was not changed since it was checked with CheckLastSucceedTag.
+func (s *Kubernetes) StoreSucceedTag(tag, commit string) error {
+	if tag == "" {
+		return fmt.Errorf("tag can't be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cm, exists, err := s.getState()
+	if err != nil {
+		return err
+	}
+	if s.stateRead && cm.ResourceVersion != s.resourceVersion {
+		return ErrConcurrentUpdate
+	}
+
+	tagCommits, err := parseTagCommits(cm)
+	if err != nil {
+		return err
+	}
+	tagCommits[tag] = commit
+	data, err := json.Marshal(tagCommits)
+	if err != nil {
+		return fmt.Errorf("unable to marshal tag commits: %w", err)
+	}
+	cm.Data[keyLastProcessedTag] = tag
+	cm.Data[keyTagCommits] = string(data)
+
+	ctx := context.Background()
+	var res *corev1.ConfigMap
+	if !exists {
+		res, err = s.client.CoreV1().ConfigMaps(s.namespace).Create(ctx, cm, metav1.CreateOptions{})
+	} else {
+		res, err = s.client.CoreV1().ConfigMaps(s.namespace).Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) {
+		return ErrConcurrentUpdate
+	}
+	if err != nil {
+		return fmt.Errorf("error write configmap %s: %w", s.name, err)
+	}
+
+	s.stateRead, s.resourceVersion = true, res.ResourceVersion
+	return nil
+}
+
+func (s *Kubernetes) TagCommits() (map[string]string, error) {
+	cm, _, err := s.getState()
+	if err != nil {
+		return nil, err
+	}
+	return parseTagCommits(cm)
+}
+
+func (s *Kubernetes) StoreRecord(record history.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal history record: %w", err)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.name + "-" + strings.ToLower(record.ID),
+			Namespace: s.namespace,
+			Labels: map[string]string{
+				labelManagedBy: "trx",
+				labelState:     s.name,
+				labelHistory:   "true",
+			},
+		},
+		Data: map[string]string{keyRecord: string(data)},
+	}
+	if _, err := s.client.CoreV1().ConfigMaps(s.namespace).Create(context.Background(), cm, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("error create history configmap %s: %w", cm.Name, err)
+	}
+	return nil
+}
+
+func (s *Kubernetes) History(query history.Query) ([]history.Record, error) {
+	list, err := s.client.CoreV1().ConfigMaps(s.namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{labelState: s.name, labelHistory: "true"}).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error list history configmaps: %w", err)
+	}
+
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	records := make([]history.Record, 0, len(items))
+	for _, cm := range items {
+		var record history.Record
+		if err := json.Unmarshal([]byte(cm.Data[keyRecord]), &record); err != nil {
+			return nil, fmt.Errorf("error parse history configmap %s: %w", cm.Name, err)
+		}
+		records = append(records, record)
+	}
+	return history.Filter(records, query), nil
+}
+
+// getState returns the state ConfigMap, or a new one if it does not exist yet.
+func (s *Kubernetes) getState() (*corev1.ConfigMap, bool, error) {
+	exists := true
+	cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(context.Background(), s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		exists = false
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.name,
+				Namespace: s.namespace,
+				Labels:    map[string]string{labelManagedBy: "trx"},
+			},
+		}
+	} else if err != nil {
+		return nil, false, fmt.Errorf("error get configmap %s: %w", s.name, err)
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	return cm, exists, nil
+}
+
+func parseTagCommits(cm *corev1.ConfigMap) (map[string]string, error) {
+	tagCommits := make(map[string]string)
+	if data := cm.Data[keyTagCommits]; data != "" {
+		if err := json.Unmarshal([]byte(data), &tagCommits); err != nil {
+			return nil, fmt.Errorf("error parse tag commits from configmap %s: %w", cm.Name, err)
+		}
+	}
+	return tagCommits, nil
+}
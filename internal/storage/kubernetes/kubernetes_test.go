@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"trx/internal/history"
+)
+
+func TestKubernetes_SucceedTag(t *testing.T) {
+	client := fake.NewClientset()
+	s := NewKubernetesStorage(client, "default", "trx-state")
+
+	tag, err := s.CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "", tag)
+
+	require.NoError(t, s.StoreSucceedTag("v1.0.0", "aaa"))
+	require.NoError(t, s.StoreSucceedTag("v1.1.0", "bbb"))
+
+	other := NewKubernetesStorage(client, "default", "trx-state")
+	tag, err = other.CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", tag)
+
+	tagCommits, err := other.TagCommits()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"v1.0.0": "aaa", "v1.1.0": "bbb"}, tagCommits)
+}
+
+func TestKubernetes_ConcurrentUpdate(t *testing.T) {
+	client := fake.NewClientset()
+	s := NewKubernetesStorage(client, "default", "trx-state")
+	require.NoError(t, s.StoreSucceedTag("v1.0.0", "aaa"))
+
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "trx-state", nil)
+	})
+	assert.ErrorIs(t, s.StoreSucceedTag("v1.1.0", "bbb"), ErrConcurrentUpdate)
+}
+
+func TestKubernetes_History(t *testing.T) {
+	client := fake.NewClientset()
+	s := NewKubernetesStorage(client, "default", "trx-state")
+	other := NewKubernetesStorage(client, "default", "trx-other")
+
+	for i, tag := range []string{"v1.0.0", "v1.1.0", "v1.2.0"} {
+		record := history.NewRecord(history.KindRun, fmt.Sprintf("20240101T00000%dZ-00000000", i), tag, "commit")
+		record.Finish(nil)
+		require.NoError(t, s.StoreRecord(*record))
+	}
+	require.NoError(t, other.StoreRecord(*history.NewRecord(history.KindRun, "20240101T000000Z-ffffffff", "v9.0.0", "commit")))
+
+	records, err := s.History(history.Query{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "v1.0.0", records[0].Tag)
+	assert.Equal(t, "v1.2.0", records[2].Tag)
+
+	records, err = s.History(history.Query{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "v1.2.0", records[0].Tag)
+}
@@ -1,32 +1,82 @@
 package storage
 
 import (
+	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
 
 	"trx/internal/git"
+	"trx/internal/history"
 )
 
 const TypeLocalStorage = "local"
 
 const (
 	fileLastProcessedCommit = "last_processed_commit"
+	fileHistory             = "history"
+	fileTagCommits          = "tag_commits"
+
+	maxHistoryRecordSize = 10 * 1024 * 1024
 )
 
 type Local struct {
 	path string
 }
 
-func NewLocalStorage(repoUrl string) *Local {
-	usr, _ := user.Current()
-	repoName := git.RepoNameFromUrl(repoUrl)
+// NewLocalStorage creates the storage in the repository directory under the base path, ~/.trx/storage by default.
+func NewLocalStorage(repoUrl, basePath string) (*Local, error) {
+	if basePath != "" {
+		return &Local{
+			path: filepath.Join(basePath, git.RepoKeyFromUrl(repoUrl)),
+		}, nil
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	storagePath := filepath.Join(usr.HomeDir, ".trx", "storage")
+	path := filepath.Join(storagePath, git.RepoKeyFromUrl(repoUrl))
+
+	legacyClonePath, err := git.LegacyClonePath(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+	legacyPath := filepath.Join(storagePath, git.RepoNameFromUrl(repoUrl))
+	if err := migrateLegacyStorage(repoUrl, legacyPath, path, legacyClonePath); err != nil {
+		return nil, fmt.Errorf("unable to migrate legacy storage: %w", err)
+	}
 	return &Local{
-		path: filepath.Join(usr.HomeDir, ".trx", "storage", repoName),
+		path: path,
+	}, nil
+}
+
+// migrateLegacyStorage moves the storage keyed by the repository name only to the new path.
+// The storage is not moved if the legacy clone with the same name belongs to another repository.
+// The legacy clone is only a cache, so the storage is moved if the clone has been deleted or moved to the new path.
+func migrateLegacyStorage(repoUrl, legacyPath, path, legacyClonePath string) error {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(legacyPath, fileLastProcessedCommit)); err != nil {
+		return nil
+	}
+
+	if _, err := os.Stat(legacyClonePath); !os.IsNotExist(err) && !git.IsCloneOf(legacyClonePath, repoUrl) {
+		return fmt.Errorf("storage %s belongs to the repository cloned to %s, not to %s", legacyPath, legacyClonePath, repoUrl)
+	}
+
+	log.Printf("Moving storage from %s to %s\n", legacyPath, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
 	}
+	return os.Rename(legacyPath, path)
 }
 
 func (s *Local) CheckLastSucceedTag() (string, error) {
@@ -48,8 +98,8 @@ func (s *Local) CheckLastSucceedTag() (string, error) {
 	return commit, nil
 }
 
-func (s *Local) StoreSucceedTag(commit string) error {
-	if commit == "" {
+func (s *Local) StoreSucceedTag(tag, commit string) error {
+	if tag == "" {
 		return fmt.Errorf("tag can't be empty")
 	}
 
@@ -57,7 +107,89 @@ func (s *Local) StoreSucceedTag(commit string) error {
 		return err
 	}
 
+	tagCommits, err := s.TagCommits()
+	if err != nil {
+		return err
+	}
+	tagCommits[tag] = commit
+	data, err := json.MarshalIndent(tagCommits, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal tag commits: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.path, fileTagCommits), data, 0o644); err != nil {
+		return err
+	}
+
 	filePath := filepath.Join(s.path, fileLastProcessedCommit)
 
-	return os.WriteFile(filePath, []byte(commit+"\n"), 0o644)
+	return os.WriteFile(filePath, []byte(tag+"\n"), 0o644)
+}
+
+func (s *Local) TagCommits() (map[string]string, error) {
+	tagCommits := make(map[string]string)
+
+	data, err := os.ReadFile(filepath.Join(s.path, fileTagCommits))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return tagCommits, nil
+		}
+		return nil, fmt.Errorf("error read from local storage: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &tagCommits); err != nil {
+		return nil, fmt.Errorf("error parse local storage tag commits: %w", err)
+	}
+	return tagCommits, nil
+}
+
+func (s *Local) StoreRecord(record history.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal history record: %w", err)
+	}
+
+	if err := os.MkdirAll(s.path, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.path, fileHistory), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error open local storage history: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error write to local storage history: %w", err)
+	}
+	return f.Sync()
+}
+
+func (s *Local) History(query history.Query) ([]history.Record, error) {
+	f, err := os.Open(filepath.Join(s.path, fileHistory))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error read local storage history: %w", err)
+	}
+	defer f.Close()
+
+	var records []history.Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxHistoryRecordSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record history.Record
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, fmt.Errorf("error parse local storage history record: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error read local storage history: %w", err)
+	}
+	return history.Filter(records, query), nil
 }
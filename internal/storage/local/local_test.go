@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trx/internal/history"
+)
+
+const testRepoUrl = "https://github.com/example/app.git"
+
+func TestLocal_SucceedTag(t *testing.T) {
+	base := t.TempDir()
+	s, err := NewLocalStorage(testRepoUrl, base)
+	require.NoError(t, err)
+
+	tag, err := s.CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "", tag)
+
+	require.NoError(t, s.StoreSucceedTag("v1.0.0", "aaa"))
+	require.NoError(t, s.StoreSucceedTag("v1.1.0", "bbb"))
+	assert.Error(t, s.StoreSucceedTag("", "ccc"))
+
+	other, err := NewLocalStorage(testRepoUrl, base)
+	require.NoError(t, err)
+	tag, err = other.CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", tag)
+
+	tagCommits, err := other.TagCommits()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"v1.0.0": "aaa", "v1.1.0": "bbb"}, tagCommits)
+}
+
+func TestLocal_RepoKey(t *testing.T) {
+	base := t.TempDir()
+	s, err := NewLocalStorage(testRepoUrl, base)
+	require.NoError(t, err)
+	other, err := NewLocalStorage("https://gitlab.com/other/app.git", base)
+	require.NoError(t, err)
+
+	require.NoError(t, s.StoreSucceedTag("v1.0.0", "aaa"))
+
+	tag, err := other.CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "", tag)
+	assert.FileExists(t, filepath.Join(base, "github.com", "example", "app", fileLastProcessedCommit))
+}
+
+func TestLocal_LegacyState(t *testing.T) {
+	s := &Local{path: t.TempDir()}
+	require.NoError(t, os.WriteFile(filepath.Join(s.path, fileLastProcessedCommit), []byte("v0.9.0\n"), 0o644))
+
+	tag, err := s.CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "v0.9.0", tag)
+
+	tagCommits, err := s.TagCommits()
+	require.NoError(t, err)
+	assert.Empty(t, tagCommits)
+}
+
+func TestLocal_History(t *testing.T) {
+	base := t.TempDir()
+	s, err := NewLocalStorage(testRepoUrl, base)
+	require.NoError(t, err)
+
+	records, err := s.History(history.Query{})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	for i, tag := range []string{"v1.0.0", "v1.1.0", "v1.2.0"} {
+		record := history.NewRecord(history.KindRun, fmt.Sprintf("20240101T00000%dZ-00000000", i), tag, "commit")
+		record.Finish(nil)
+		require.NoError(t, s.StoreRecord(*record))
+	}
+
+	records, err = s.History(history.Query{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "v1.0.0", records[0].Tag)
+	assert.Equal(t, "v1.2.0", records[2].Tag)
+
+	records, err = s.History(history.Query{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "v1.2.0", records[0].Tag)
+}
+
+func TestMigrateLegacyStorage(t *testing.T) {
+	tcs := []struct {
+		name      string
+		legacy    string
+		clone     string
+		expectErr bool
+	}{
+		{name: "legacy clone of the repository", legacy: testRepoUrl},
+		{name: "clone already migrated", clone: testRepoUrl},
+		{name: "legacy clone deleted"},
+		{name: "legacy clone of another repository", legacy: "https://gitlab.com/other/app.git", clone: testRepoUrl, expectErr: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			legacyPath := filepath.Join(dir, "storage", "app")
+			path := filepath.Join(dir, "storage", "github.com", "example", "app")
+			legacyClonePath := filepath.Join(dir, "app")
+			clonePath := filepath.Join(dir, "repos", "github.com", "example", "app")
+			if tc.legacy != "" {
+				initClone(t, legacyClonePath, tc.legacy)
+			}
+			if tc.clone != "" {
+				initClone(t, clonePath, tc.clone)
+			}
+			legacy := &Local{path: legacyPath}
+			require.NoError(t, legacy.StoreSucceedTag("v1.0.0", "aaa"))
+
+			err := migrateLegacyStorage(testRepoUrl, legacyPath, path, legacyClonePath)
+			if tc.expectErr {
+				assert.Error(t, err)
+				assert.DirExists(t, legacyPath)
+				assert.NoDirExists(t, path)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoDirExists(t, legacyPath)
+
+			tag, err := (&Local{path: path}).CheckLastSucceedTag()
+			require.NoError(t, err)
+			assert.Equal(t, "v1.0.0", tag)
+		})
+	}
+}
+
+func TestMigrateLegacyStorage_newStorageExists(t *testing.T) {
+	dir := t.TempDir()
+	legacyPath := filepath.Join(dir, "storage", "app")
+	path := filepath.Join(dir, "storage", "github.com", "example", "app")
+	require.NoError(t, (&Local{path: legacyPath}).StoreSucceedTag("v1.0.0", "aaa"))
+	require.NoError(t, (&Local{path: path}).StoreSucceedTag("v2.0.0", "bbb"))
+
+	require.NoError(t, migrateLegacyStorage(testRepoUrl, legacyPath, path, filepath.Join(dir, "app")))
+
+	tag, err := (&Local{path: path}).CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "v2.0.0", tag)
+	assert.DirExists(t, legacyPath)
+}
+
+func initClone(t *testing.T, path, url string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(path, 0o755))
+	repo, err := git.PlainInit(path, false)
+	require.NoError(t, err)
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+	require.NoError(t, err)
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+
+	"trx/internal/config"
+	"trx/internal/git"
+	"trx/internal/history"
+	"trx/internal/s3"
+)
+
+const TypeS3Storage = "s3"
+
+const (
+	objectState   = "state.json"
+	objectHistory = "history"
+)
+
+var ErrConcurrentUpdate = errors.New("storage state was modified by another run")
+
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+
+	mu        sync.Mutex
+	stateRead bool
+	stateETag string
+}
+
+type state struct {
+	LastProcessedTag string            `json:"lastProcessedTag"`
+	TagCommits       map[string]string `json:"tagCommits"`
+}
+
+func NewS3Storage(repoUrl string, cfg *config.S3) (*S3, error) {
+	client, err := s3.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &S3{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: path.Join(strings.Trim(cfg.Prefix, "/"), git.RepoKeyFromUrl(repoUrl)),
+	}, nil
+}
+
+func (s *S3) CheckLastSucceedTag() (string, error) {
+	st, etag, err := s.readState()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.stateRead, s.stateETag = true, etag
+	s.mu.Unlock()
+
+	return st.LastProcessedTag, nil
+}
+
+// StoreSucceedTag stores the tag only if the state was not changed since it was checked with CheckLastSucceedTag.
+func (s *S3) StoreSucceedTag(tag, commit string) error {
+	if tag == "" {
+		return fmt.Errorf("tag can't be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, etag, err := s.readState()
+	if err != nil {
+		return err
+	}
+	if s.stateRead && etag != s.stateETag {
+		return ErrConcurrentUpdate
+	}
+
+	st.LastProcessedTag = tag
+	st.TagCommits[tag] = commit
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("unable to marshal state: %w", err)
+	}
+
+	newETag, err := s.putObject(s.key(objectState), data, etag)
+	if err != nil {
+		return err
+	}
+	s.stateRead, s.stateETag = true, newETag
+	return nil
+}
+
+func (s *S3) TagCommits() (map[string]string, error) {
+	st, _, err := s.readState()
+	if err != nil {
+		return nil, err
+	}
+	return st.TagCommits, nil
+}
+
+func (s *S3) StoreRecord(record history.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal history record: %w", err)
+	}
+	_, err = s.putObject(s.key(objectHistory, record.ID+".json"), data, "")
+	return err
+}
+
+func (s *S3) History(query history.Query) ([]history.Record, error) {
+	ctx := context.Background()
+
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.key(objectHistory) + "/",
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("error list s3 storage history: %w", obj.Err)
+		}
+		keys = append(keys, obj.Key)
+	}
+	sort.Strings(keys)
+
+	if query.Kind == "" && query.Tag == "" && query.Status == "" && query.Limit > 0 && len(keys) > query.Limit {
+		keys = keys[len(keys)-query.Limit:]
+	}
+
+	records := make([]history.Record, 0, len(keys))
+	for _, key := range keys {
+		data, _, err := s.getObject(key)
+		if err != nil {
+			return nil, err
+		}
+		var record history.Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("error parse s3 storage history record %s: %w", key, err)
+		}
+		records = append(records, record)
+	}
+	return history.Filter(records, query), nil
+}
+
+func (s *S3) key(parts ...string) string {
+	return path.Join(append([]string{s.prefix}, parts...)...)
+}
+
+func (s *S3) readState() (*state, string, error) {
+	st := &state{}
+	data, etag, err := s.getObject(s.key(objectState))
+	if err != nil && !s3.IsNotFound(err) {
+		return nil, "", err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, st); err != nil {
+			return nil, "", fmt.Errorf("error parse s3 storage state: %w", err)
+		}
+	}
+	if st.TagCommits == nil {
+		st.TagCommits = make(map[string]string)
+	}
+	return st, etag, nil
+}
+
+func (s *S3) getObject(key string) ([]byte, string, error) {
+	obj, err := s.client.GetObject(context.Background(), s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", fmt.Errorf("error read %s from s3 storage: %w", key, err)
+	}
+	defer obj.Close()
+
+	info, err := obj.Stat()
+	if err != nil {
+		return nil, "", fmt.Errorf("error read %s from s3 storage: %w", key, err)
+	}
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, "", fmt.Errorf("error read %s from s3 storage: %w", key, err)
+	}
+	return data, info.ETag, nil
+}
+
+// putObject writes the object if its current ETag matches the given one, or if it does not exist when the ETag is empty.
+func (s *S3) putObject(key string, data []byte, etag string) (string, error) {
+	opts := minio.PutObjectOptions{ContentType: "application/json"}
+	if etag == "" {
+		opts.SetMatchETagExcept("*")
+	} else {
+		opts.SetMatchETag(etag)
+	}
+
+	info, err := s.client.PutObject(context.Background(), s.bucket, key, bytes.NewReader(data), int64(len(data)), opts)
+	if err != nil {
+		if s3.IsPreconditionFailed(err) {
+			return "", ErrConcurrentUpdate
+		}
+		return "", fmt.Errorf("error write %s to s3 storage: %w", key, err)
+	}
+	return info.ETag, nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"trx/internal/history"
+	"trx/internal/s3/s3test"
+)
+
+const testRepoUrl = "git@github.com:flant/trx.git"
+
+func TestS3_SucceedTag(t *testing.T) {
+	cfg := s3test.NewServer(t)
+
+	s, err := NewS3Storage(testRepoUrl, cfg)
+	require.NoError(t, err)
+
+	tag, err := s.CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "", tag)
+
+	require.NoError(t, s.StoreSucceedTag("v1.0.0", "aaa"))
+	require.NoError(t, s.StoreSucceedTag("v1.1.0", "bbb"))
+
+	other, err := NewS3Storage(testRepoUrl, cfg)
+	require.NoError(t, err)
+	tag, err = other.CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", tag)
+
+	tagCommits, err := other.TagCommits()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"v1.0.0": "aaa", "v1.1.0": "bbb"}, tagCommits)
+}
+
+func TestS3_ConcurrentUpdate(t *testing.T) {
+	cfg := s3test.NewServer(t)
+
+	first, err := NewS3Storage(testRepoUrl, cfg)
+	require.NoError(t, err)
+	second, err := NewS3Storage(testRepoUrl, cfg)
+	require.NoError(t, err)
+
+	_, err = first.CheckLastSucceedTag()
+	require.NoError(t, err)
+	_, err = second.CheckLastSucceedTag()
+	require.NoError(t, err)
+
+	require.NoError(t, second.StoreSucceedTag("v2.0.0", "bbb"))
+	assert.ErrorIs(t, first.StoreSucceedTag("v1.0.0", "aaa"), ErrConcurrentUpdate)
+
+	tag, err := first.CheckLastSucceedTag()
+	require.NoError(t, err)
+	assert.Equal(t, "v2.0.0", tag)
+}
+
+func TestS3_History(t *testing.T) {
+	cfg := s3test.NewServer(t)
+
+	s, err := NewS3Storage(testRepoUrl, cfg)
+	require.NoError(t, err)
+
+	for i, status := range []string{history.StatusSucceeded, history.StatusFailed, history.StatusSucceeded} {
+		require.NoError(t, s.StoreRecord(history.Record{
+			ID:     fmt.Sprintf("20250101T00000%dZ-0000", i),
+			Kind:   history.KindRun,
+			Tag:    fmt.Sprintf("v1.%d.0", i),
+			Status: status,
+		}))
+	}
+
+	records, err := s.History(history.Query{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "v1.0.0", records[0].Tag)
+
+	records, err = s.History(history.Query{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "v1.2.0", records[0].Tag)
+
+	records, err = s.History(history.Query{Status: history.StatusFailed})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "v1.1.0", records[0].Tag)
+}
@@ -1,13 +1,22 @@
 package storage
 
 import (
+	"fmt"
+
 	"trx/internal/config"
+	"trx/internal/history"
+	"trx/internal/kube"
+	k8s "trx/internal/storage/kubernetes"
 	local "trx/internal/storage/local"
+	s3 "trx/internal/storage/s3"
 )
 
 type Storage interface {
 	CheckLastSucceedTag() (string, error)
-	StoreSucceedTag(commit string) error
+	StoreSucceedTag(tag, commit string) error
+	TagCommits() (map[string]string, error)
+	StoreRecord(record history.Record) error
+	History(query history.Query) ([]history.Record, error)
 }
 
 type StorageService struct {
@@ -20,11 +29,52 @@ type StorageOpts struct {
 }
 
 func NewStorage(opts *StorageOpts) (*StorageService, error) {
-	switch opts.StorageType {
-	case "local":
-		return &StorageService{storage: local.NewLocalStorage(opts.Config.Repo.Url)}, nil
+	cfg := opts.Config.Storage
+	if cfg == nil {
+		cfg = &config.Storage{}
+	}
+
+	storageType := opts.StorageType
+	if storageType == "" {
+		storageType = cfg.Type
+	}
+
+	switch storageType {
+	case "", local.TypeLocalStorage:
+		var path string
+		if cfg.Local != nil {
+			path = cfg.Local.Path
+		}
+		storage, err := local.NewLocalStorage(opts.Config.Repo.Url, path)
+		if err != nil {
+			return nil, err
+		}
+		return &StorageService{storage: storage}, nil
+	case s3.TypeS3Storage:
+		if cfg.S3 == nil {
+			return nil, fmt.Errorf("s3 storage is not configured")
+		}
+		storage, err := s3.NewS3Storage(opts.Config.Repo.Url, cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		return &StorageService{storage: storage}, nil
+	case k8s.TypeKubernetesStorage:
+		kubeCfg := cfg.Kubernetes
+		if kubeCfg == nil {
+			kubeCfg = &config.KubernetesStorage{}
+		}
+		client, namespace, err := kube.NewClient(kubeCfg.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+		name := kubeCfg.ConfigMap
+		if name == "" {
+			name = kube.ResourceName(opts.Config.Repo.Url)
+		}
+		return &StorageService{storage: k8s.NewKubernetesStorage(client, namespace, name)}, nil
 	default:
-		return &StorageService{storage: local.NewLocalStorage(opts.Config.Repo.Url)}, nil
+		return nil, fmt.Errorf("unknown storage type %q", storageType)
 	}
 }
 
@@ -32,6 +82,18 @@ func (s *StorageService) CheckLastSucceedTag() (string, error) {
 	return s.storage.CheckLastSucceedTag()
 }
 
-func (s *StorageService) StoreSucceedTag(commit string) error {
-	return s.storage.StoreSucceedTag(commit)
+func (s *StorageService) StoreSucceedTag(tag, commit string) error {
+	return s.storage.StoreSucceedTag(tag, commit)
+}
+
+func (s *StorageService) TagCommits() (map[string]string, error) {
+	return s.storage.TagCommits()
+}
+
+func (s *StorageService) StoreRecord(record history.Record) error {
+	return s.storage.StoreRecord(record)
+}
+
+func (s *StorageService) History(query history.Query) ([]history.Record, error) {
+	return s.storage.History(query)
 }